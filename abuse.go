@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Abuse heuristics. A client earns a strike for repeating the same question
+// too often or asking too fast, and each strike makes the orb ponder longer.
+const (
+	maxRepeatedQuestions = 3                // Identical questions in a row before a strike
+	maxQuestionsPerMin   = 12               // Questions in a minute before a strike
+	shadowDelayBase      = 2 * time.Second  // Delay after the first strike, doubling after
+	maxShadowDelay       = 2 * time.Minute  // Longest the orb will stall
+	strikeAmnesty        = 30 * time.Minute // Strikes are forgotten after this long
+)
+
+// Clients currently under a shadow cooldown and why, for operators
+var metricFlaggedClients = expvar.NewMap("flagged_clients")
+
+// Fingerprints of public keys known to abuse the orb, set by --blocked-keys
+var blockedKeys = map[string]bool{}
+
+// What the tracker remembers about a client
+type clientRecord struct {
+	asked      []time.Time // Questions within the last minute
+	last       string
+	repeats    int
+	strikes    int
+	lastStrike time.Time
+	flag       string // Why the client is under a cooldown
+}
+
+// abuseTracker applies shadow cooldowns to misbehaving clients.
+type abuseTracker struct {
+	mu      sync.Mutex
+	clients map[string]*clientRecord
+}
+
+var abuse = &abuseTracker{clients: make(map[string]*clientRecord)}
+
+// observe records a question from a client and returns how long the orb
+// should quietly stall before answering it.
+func (t *abuseTracker) observe(client, question string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.forget(now)
+	r, ok := t.clients[client]
+	if !ok {
+		r = &clientRecord{}
+		t.clients[client] = r
+	}
+	if r.strikes > 0 && now.Sub(r.lastStrike) > strikeAmnesty {
+		r.strikes = 0
+		metricFlaggedClients.Delete(client)
+	}
+
+	recent := r.asked[:0]
+	for _, at := range r.asked {
+		if now.Sub(at) < time.Minute {
+			recent = append(recent, at)
+		}
+	}
+	r.asked = append(recent, now)
+
+	normalized := strings.ToLower(strings.TrimSpace(question))
+	if normalized == r.last {
+		r.repeats++
+	} else {
+		r.last, r.repeats = normalized, 1
+	}
+
+	switch {
+	case r.repeats > maxRepeatedQuestions:
+		t.strike(client, r, now, "repeated question")
+	case len(r.asked) > maxQuestionsPerMin:
+		t.strike(client, r, now, "high frequency")
+	}
+	if r.strikes == 0 {
+		return 0
+	}
+	delay := time.Duration(float64(shadowDelayBase) * math.Pow(2, float64(r.strikes-1)))
+	return min(delay, maxShadowDelay)
+}
+
+// forget drops clients with no questions in the last minute and no strikes
+// left to serve, so the tracker doesn't grow with every client ever seen.
+func (t *abuseTracker) forget(now time.Time) {
+	for client, r := range t.clients {
+		quiet := len(r.asked) == 0 || now.Sub(r.asked[len(r.asked)-1]) >= time.Minute
+		pardoned := r.strikes == 0 || now.Sub(r.lastStrike) > strikeAmnesty
+		if quiet && pardoned {
+			delete(t.clients, client)
+			metricFlaggedClients.Delete(client)
+		}
+	}
+}
+
+func (t *abuseTracker) strike(client string, r *clientRecord, now time.Time, reason string) {
+	r.strikes++
+	r.lastStrike = now
+	note := fmt.Sprintf("%s, %d strikes", reason, r.strikes)
+	r.flag = note
+	metricFlaggedClients.Set(client, expvarString(note))
+	slog.Warn("shadow cooldown", "client", client, "reason", note)
+}
+
+// flagBlockedKey marks a client whose key is on the block list; it starts
+// with enough strikes to stall for as long as the orb ever does.
+func (t *abuseTracker) flagBlockedKey(client string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.clients[client]
+	if !ok {
+		r = &clientRecord{}
+		t.clients[client] = r
+	}
+	if r.strikes == 0 {
+		r.strikes = int(math.Ceil(math.Log2(float64(maxShadowDelay/shadowDelayBase)))) + 1
+		r.lastStrike = time.Now()
+		r.flag = "blocked key"
+		metricFlaggedClients.Set(client, expvarString(r.flag))
+		slog.Warn("shadow cooldown", "client", client, "reason", "blocked key")
+	}
+}
+
+// flagged returns why a client is under a shadow cooldown, or "" when it
+// isn't.
+func (t *abuseTracker) flagged(client string, now time.Time) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.clients[client]
+	if !ok || r.strikes == 0 || now.Sub(r.lastStrike) > strikeAmnesty {
+		return ""
+	}
+	return r.flag
+}
+
+// shadowCooldown stalls an answer command without telling anyone why.
+func shadowCooldown(delay time.Duration, cmd tea.Cmd) tea.Cmd {
+	return func() tea.Msg {
+		time.Sleep(delay)
+		return cmd()
+	}
+}
+
+func expvarString(s string) *expvar.String {
+	v := new(expvar.String)
+	v.Set(s)
+	return v
+}
+
+// clientID identifies the client behind a session by its public key, or by
+// its address when it didn't offer one.
+func clientID(s ssh.Session) string {
+	if fingerprint := keyFingerprint(s); fingerprint != "" {
+		return fingerprint
+	}
+	return remoteIP(s)
+}
+
+// loadBlockedKeys reads public keys in authorized_keys format.
+func loadBlockedKeys(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open blocked keys: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return fmt.Errorf("failed to parse blocked key: %w", err)
+		}
+		blockedKeys[gossh.FingerprintSHA256(key)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read blocked keys: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/charmbracelet/wish/bubbletea"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Fingerprints of the keys let into the admin dashboard, set by --admin-keys
+var adminKeys = map[string]bool{}
+
+// The command that opens the admin dashboard: ssh -t ponder.guru admin
+const adminCommand = "admin"
+
+// How often the dashboard refreshes its sessions
+const adminRefresh = time.Second
+
+// loadAdminKeys reads public keys in authorized_keys format.
+func loadAdminKeys(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open admin keys: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return fmt.Errorf("failed to parse admin key: %w", err)
+		}
+		adminKeys[gossh.FingerprintSHA256(key)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read admin keys: %w", err)
+	}
+	return nil
+}
+
+// adminMode opens the admin dashboard instead of the orb for clients with
+// an admin key that run the admin command. Without a terminal it prints
+// the sessions once.
+func adminMode() wish.Middleware {
+	dashboard := bubbletea.Middleware(adminHandler)(func(ssh.Session) {})
+	return func(next ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			if cmd := s.Command(); len(adminKeys) == 0 || len(cmd) != 1 || cmd[0] != adminCommand {
+				next(s)
+				return
+			}
+			logger := sessionLogger(s)
+			if !adminKeys[keyFingerprint(s)] {
+				logger.Warn("refused admin session", "identity", keyFingerprint(s))
+				tell(s, "The orb doesn't know you as an admin.")
+				s.Exit(1)
+				return
+			}
+			logger.Info("admin session", "identity", keyFingerprint(s))
+			if _, _, active := s.Pty(); !active {
+				writeSessions(s, activeSessions.list(), time.Now())
+				s.Exit(0)
+				return
+			}
+			dashboard(s)
+		}
+	}
+}
+
+func adminHandler(s ssh.Session) (tea.Model, []tea.ProgramOption) {
+	renderer := bubbletea.MakeRenderer(s)
+	styles := table.Styles{
+		Header:   renderer.NewStyle().Bold(true).Padding(0, 1).Foreground(lipgloss.Color("155")),
+		Cell:     renderer.NewStyle().Padding(0, 1),
+		Selected: renderer.NewStyle().Foreground(lipgloss.Color("#FFF")).Background(lipgloss.Color("#222")),
+	}
+	d := adminDashboard{
+		table: table.New(
+			table.WithColumns(sessionColumns),
+			table.WithStyles(styles),
+			table.WithFocused(true),
+		),
+		self:     s.Context().SessionID(),
+		newStyle: renderer.NewStyle,
+		logger:   sessionLogger(s),
+	}
+	d.refresh(time.Now())
+	return d, []tea.ProgramOption{tea.WithAltScreen()}
+}
+
+// Columns of the session table
+var sessionColumns = []table.Column{
+	{Title: "Session", Width: 18},
+	{Title: "Remote", Width: 22},
+	{Title: "Client", Width: 20},
+	{Title: "Uptime", Width: 10},
+	{Title: "Questions", Width: 9},
+	{Title: "Flags", Width: 24},
+}
+
+// sessionRow describes a session in the columns of sessionColumns.
+func sessionRow(info sessionInfo, now time.Time) table.Row {
+	client := "no key"
+	switch {
+	case info.identity != "":
+		client = displayName(info.identity)
+	case info.web:
+		client = "browser"
+	}
+	return table.Row{
+		truncateRunes(info.id, 12),
+		info.remote,
+		client,
+		now.Sub(info.started).Round(time.Second).String(),
+		strconv.Itoa(info.questions),
+		abuse.flagged(info.client, now),
+	}
+}
+
+// writeSessions prints the sessions as a plain table.
+func writeSessions(w io.Writer, sessions []sessionInfo, now time.Time) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	titles := make([]string, len(sessionColumns))
+	for i, c := range sessionColumns {
+		titles[i] = strings.ToUpper(c.Title)
+	}
+	fmt.Fprintln(tw, strings.Join(titles, "\t"))
+	for _, info := range sessions {
+		fmt.Fprintln(tw, strings.Join(sessionRow(info, now), "\t"))
+	}
+	tw.Flush()
+}
+
+// A message to refresh the dashboard
+type adminTickMsg time.Time
+
+func adminTickCmd() tea.Cmd {
+	return tea.Tick(adminRefresh, func(t time.Time) tea.Msg { return adminTickMsg(t) })
+}
+
+// The admin dashboard: a live table of the sessions running the orb
+type adminDashboard struct {
+	table    table.Model
+	sessions []sessionInfo // In the table's order
+	self     string        // The admin's own session, which they can't disconnect
+	confirm  string        // Session waiting for the admin to confirm its disconnection
+	status   string
+	newStyle func() lipgloss.Style
+	logger   *slog.Logger
+}
+
+// refresh reloads the sessions into the table, keeping the cursor on the
+// session it was on.
+func (d *adminDashboard) refresh(now time.Time) {
+	selected := ""
+	if i := d.table.Cursor(); i >= 0 && i < len(d.sessions) {
+		selected = d.sessions[i].id
+	}
+	d.sessions = activeSessions.list()
+	rows := make([]table.Row, len(d.sessions))
+	cursor := 0
+	for i, info := range d.sessions {
+		rows[i] = sessionRow(info, now)
+		if info.id == d.self {
+			rows[i][0] += " (you)"
+		}
+		if info.id == selected {
+			cursor = i
+		}
+	}
+	d.table.SetRows(rows)
+	d.table.SetCursor(cursor)
+}
+
+// selected returns the session under the cursor.
+func (d adminDashboard) selected() (sessionInfo, bool) {
+	i := d.table.Cursor()
+	if i < 0 || i >= len(d.sessions) {
+		return sessionInfo{}, false
+	}
+	return d.sessions[i], true
+}
+
+func (d adminDashboard) Init() tea.Cmd {
+	return adminTickCmd()
+}
+
+func (d adminDashboard) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		_, height := clampSize(msg.Width, msg.Height)
+		d.table.SetHeight(max(height-6, 3))
+		return d, nil
+
+	case adminTickMsg:
+		d.refresh(time.Time(msg))
+		return d, adminTickCmd()
+
+	case tea.KeyMsg:
+		if d.confirm != "" {
+			if msg.String() == "y" {
+				if activeSessions.disconnect(d.confirm) {
+					d.logger.Info("admin disconnected session", "disconnected", d.confirm)
+					d.status = "Disconnected " + truncateRunes(d.confirm, 12)
+				} else {
+					d.status = "That session had already gone"
+				}
+				d.refresh(time.Now())
+			} else {
+				d.status = ""
+			}
+			d.confirm = ""
+			return d, nil
+		}
+		switch msg.String() {
+		case "ctrl+c", "q", "esc":
+			return d, tea.Quit
+		case "d", "x", "delete":
+			info, ok := d.selected()
+			switch {
+			case !ok:
+			case info.id == d.self:
+				d.status = "That's your own session; quit with q instead"
+			default:
+				d.confirm = info.id
+				d.status = fmt.Sprintf("Disconnect %s from %s? [y/N]", truncateRunes(info.id, 12), info.remote)
+			}
+			return d, nil
+		}
+	}
+	var cmd tea.Cmd
+	d.table, cmd = d.table.Update(msg)
+	return d, cmd
+}
+
+func (d adminDashboard) View() string {
+	title := d.newStyle().Bold(true).Foreground(lipgloss.Color("155")).
+		Render(fmt.Sprintf("Orb sessions · %d open", len(d.sessions)))
+	help := "↑/↓ select · d disconnect · q quit"
+	if d.status != "" {
+		help = d.status
+	}
+	footer := d.newStyle().Foreground(lipgloss.Color("240")).Render(help)
+	return d.newStyle().Padding(1, 2).Render(lipgloss.JoinVertical(lipgloss.Left, title, "", d.table.View(), "", footer))
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Answer text metrics, summed so averages can be taken against answers.ok
+var (
+	metricAnswerRunes     = expvar.NewInt("answer_runes")
+	metricAnswerWords     = expvar.NewInt("answer_words")
+	metricAnswerReadingMs = expvar.NewInt("answer_reading_ms")
+	metricAnswerLanguages = expvar.NewMap("answer_languages")
+)
+
+// Average reading speeds, for words and for scripts written without spaces
+const (
+	wordsPerMinute = 200
+	runesPerMinute = 500
+)
+
+// What is recorded about the text of an answer
+type answerStats struct {
+	runes    int
+	words    int
+	language string // ISO 639-1 code, "und" when it can't be told
+	reading  time.Duration
+}
+
+func measureAnswer(answer string) answerStats {
+	s := answerStats{
+		runes:    utf8.RuneCountInString(answer),
+		words:    len(strings.Fields(answer)),
+		language: detectLanguage(answer),
+	}
+	if writtenWithoutSpaces(s.language) {
+		s.reading = time.Duration(s.runes) * time.Minute / runesPerMinute
+	} else {
+		s.reading = time.Duration(s.words) * time.Minute / wordsPerMinute
+	}
+	return s
+}
+
+func (s answerStats) String() string {
+	return fmt.Sprintf("lang=%s runes=%d words=%d read=%v", s.language, s.runes, s.words, s.reading.Round(time.Second))
+}
+
+// record adds the answer to the metrics.
+func (s answerStats) record() {
+	metricAnswerRunes.Add(int64(s.runes))
+	metricAnswerWords.Add(int64(s.words))
+	metricAnswerReadingMs.Add(s.reading.Milliseconds())
+	metricAnswerLanguages.Add(s.language, 1)
+}
+
+// readingHint is shown under long answers, e.g. "~2 min of pondering".
+func (s answerStats) readingHint() string {
+	if s.reading < time.Minute {
+		return "under a minute of pondering"
+	}
+	return fmt.Sprintf("~%d min of pondering", int(math.Round(s.reading.Minutes())))
+}
+
+// Common short words of languages written in Latin script
+var languageStopwords = map[string][]string{
+	"en": {"the", "and", "is", "you", "of", "to", "will", "your", "it", "not"},
+	"es": {"el", "la", "y", "es", "de", "que", "tu", "no", "en", "los"},
+	"fr": {"le", "la", "et", "est", "de", "que", "tu", "ne", "les", "vous"},
+	"de": {"der", "die", "und", "ist", "du", "nicht", "das", "zu", "ein", "dein"},
+	"it": {"il", "la", "e", "è", "di", "che", "non", "tu", "un", "per"},
+	"pt": {"o", "a", "e", "é", "de", "que", "não", "você", "um", "para"},
+}
+
+// Names of the languages detectLanguage knows, for prompting models
+var languageNames = map[string]string{
+	"en": "English", "es": "Spanish", "fr": "French", "de": "German", "it": "Italian", "pt": "Portuguese",
+	"ru": "Russian", "el": "Greek", "ar": "Arabic", "he": "Hebrew", "zh": "Chinese", "ja": "Japanese", "ko": "Korean",
+}
+
+// writtenWithoutSpaces reports whether a language's words run together, so
+// its text is read by the character and can wrap between any two.
+func writtenWithoutSpaces(language string) bool {
+	return language == "zh" || language == "ja"
+}
+
+// languageRule asks a model to answer in the question's language, or is
+// empty when the language can't be told.
+func languageRule(question string) string {
+	name, ok := languageNames[detectLanguage(question)]
+	if !ok {
+		return ""
+	}
+	return " Answer in " + name + ", the language of the question."
+}
+
+// detectLanguage guesses the language of a question or answer from its
+// script and, for Latin script, from its most common short words.
+func detectLanguage(text string) string {
+	var latin, cyrillic, greek, arabic, hebrew, han, kana, hangul int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Greek, r):
+			greek++
+		case unicode.Is(unicode.Arabic, r):
+			arabic++
+		case unicode.Is(unicode.Hebrew, r):
+			hebrew++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+
+	switch max(latin, cyrillic, greek, arabic, hebrew, han+kana, hangul) {
+	case 0:
+		return "und"
+	case han + kana:
+		if kana > 0 {
+			return "ja"
+		}
+		return "zh"
+	case hangul:
+		return "ko"
+	case cyrillic:
+		return "ru"
+	case greek:
+		return "el"
+	case arabic:
+		return "ar"
+	case hebrew:
+		return "he"
+	}
+
+	words := make(map[string]int)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		words[strings.TrimFunc(word, unicode.IsPunct)]++
+	}
+	best, bestScore := "und", 0
+	for lang, stopwords := range languageStopwords {
+		score := 0
+		for _, w := range stopwords {
+			score += words[w]
+		}
+		if score > bestScore || (score == bestScore && score > 0 && lang < best) {
+			best, bestScore = lang, score
+		}
+	}
+	return best
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Output of orb ask --json
+type askRecord struct {
+	Question  string `json:"question"`
+	Wisdom    string `json:"wisdom,omitempty"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// runAsk asks a single question without the TUI and prints the answer, for
+// scripts and shell aliases. The question can also be piped in:
+//
+//	orb ask "Will the build pass?"
+//	echo "Will the build pass?" | orb ask --json | jq -r .wisdom
+func runAsk(args []string) {
+	fs := flag.NewFlagSet("ask", flag.ExitOnError)
+	persona := fs.String("persona", "", "persona to answer in")
+	asJSON := fs.Bool("json", false, `print {"question", "wisdom", "latency_ms"} as JSON instead of the bare answer`)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: orb ask [flags] question...   (or the question on stdin)")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	question := strings.Join(fs.Args(), " ")
+	if question == "" || question == "-" {
+		question = readPipedQuestion()
+	}
+	question = strings.TrimSpace(question)
+	if question == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *persona != "" && !validPersona(*persona) {
+		fmt.Fprintf(os.Stderr, "unknown persona %q\n", *persona)
+		os.Exit(2)
+	}
+
+	allowEndpointHosts(wisdomEndpoint)
+	requestID := newRequestID()
+	asked := time.Now()
+	answer, err := wisdom.GetAnswer(withRequest(context.Background(), requestID, *persona), question)
+	// The answer reaches the user's terminal, which it mustn't drive
+	answer, _ = stripControl(answer)
+	rec := askRecord{Question: question, Wisdom: answer, LatencyMs: time.Since(asked).Milliseconds()}
+	if err != nil {
+		rec.Error, _ = stripControl(err.Error())
+		fmt.Fprintf(os.Stderr, "the orb is silent [req=%s]: %s\n", requestID, rec.Error)
+	}
+
+	if *asJSON {
+		json.NewEncoder(os.Stdout).Encode(rec)
+	} else if err == nil {
+		fmt.Println(answer)
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// readPipedQuestion reads the question from stdin unless it's a terminal,
+// where nobody would know to type one.
+func readPipedQuestion() string {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice != 0 {
+		return ""
+	}
+	data, err := io.ReadAll(io.LimitReader(os.Stdin, 64<<10))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read question: %v\n", err)
+		os.Exit(1)
+	}
+	return string(data)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Serializes appends to the audit log
+var auditMu sync.Mutex
+
+// auditPath is where changes to the history are logged, beside it.
+func auditPath() string {
+	return filepath.Join(filepath.Dir(historyPath), "audit.jsonl")
+}
+
+// A change someone made to the history. It names the records by request
+// ID, never by what was asked, so it doesn't keep what was deleted.
+type auditRecord struct {
+	Time       time.Time `json:"time"`
+	Identity   string    `json:"identity"`
+	Action     string    `json:"action"` // "delete" or "clear"
+	Count      int       `json:"count"`
+	RequestIDs []string  `json:"request_ids,omitempty"`
+}
+
+// recordAudit appends a change to the audit log.
+func recordAudit(rec auditRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	f, err := os.OpenFile(auditPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
@@ -1,9 +1,13 @@
 from strands import Agent
 from strands.models.gemini import GeminiModel
-from fastapi import FastAPI, Request
+from fastapi import FastAPI, Header, HTTPException, Response
 from pydantic import BaseModel, Field
+import hashlib
 import os
+import threading
+import time
 from dotenv import load_dotenv
+from sanitize import DELIMITER_RULES, wrap_question
 
 load_dotenv()
 
@@ -24,31 +28,152 @@ model = GeminiModel(
 
 app = FastAPI()
 
+PERSONAS = {
+    "orb": "You are a mystical orb of pondering that people come to for wisdom. You will provide advice or insight that is deep and relfective but must be extremely concise. Sort of like a prophetic magic 8-ball or a chinese fortune cookie. a wise guru giving spiritual guidance",
+    "genie": "You are a playful genie bound to a glowing orb. You grant wisdom instead of wishes, with theatrical flair, but your answers must be extremely concise.",
+    "seer": "You are an ancient, cryptic seer gazing into an orb. You answer in short riddles and omens that hint at the truth without stating it plainly.",
+}
+
+class IdempotencyEntry:
+    """One request by Idempotency-Key, and its outcome once the owner is done."""
+
+    def __init__(self, expires_at: float, body_hash: str):
+        self.expires_at = expires_at
+        self.body_hash = body_hash
+        self.done = threading.Event()
+        self.result = None
+        self.error = None
+
+
+class IdempotencyCache:
+    """Short-term memory of answers by Idempotency-Key.
+
+    A retried request waits for the original to finish and gets the same
+    answer, or the same error, so a client timing out never pays for a
+    second backend call. Each key is bound to a hash of the request body;
+    reusing it for a different request is an error rather than a stale
+    answer.
+    """
+
+    def __init__(self, ttl_seconds: float):
+        self.ttl = ttl_seconds
+        self.lock = threading.Lock()
+        self.entries = {}  # key -> IdempotencyEntry
+
+    def begin(self, key: str, body_hash: str):
+        """Return (owner, entry): owner is True if the caller must compute the answer.
+
+        Raises KeyError if the key was already used for a different body.
+        """
+        now = time.monotonic()
+        with self.lock:
+            for k in [k for k, e in self.entries.items() if e.expires_at < now]:
+                del self.entries[k]
+            entry = self.entries.get(key)
+            if entry is not None:
+                if entry.body_hash != body_hash:
+                    raise KeyError(key)
+                return False, entry
+            entry = IdempotencyEntry(now + self.ttl, body_hash)
+            self.entries[key] = entry
+            return True, entry
+
+    def finish(self, entry: IdempotencyEntry, result):
+        entry.result = result
+        entry.done.set()
+
+    def fail(self, key: str, entry: IdempotencyEntry, error: Exception):
+        """Hand the owner's error to the requests waiting on it.
+
+        Later retries get a fresh attempt, since the key is forgotten.
+        """
+        entry.error = error
+        with self.lock:
+            if self.entries.get(key) is entry:
+                del self.entries[key]
+        entry.done.set()
+
+
+def body_hash(r: "Inquery") -> str:
+    """Fingerprint of everything that affects the answer."""
+    return hashlib.sha256(r.model_dump_json().encode()).hexdigest()
+
+
+idempotency = IdempotencyCache(ttl_seconds=600)
+
+# How long a retry waits for the original request before giving up
+IDEMPOTENCY_WAIT_SECONDS = 120
+
+
+class Exchange(BaseModel):
+    question: str
+    answer: str
+
 
 class Inquery(BaseModel):
     question: str = Field(..., examples=[
                           "Will I be too cold without a jacket?"])
+    persona: str = Field("orb", examples=["genie"])
+    # Earlier questions and answers, oldest first, so follow-ups make sense
+    history: list[Exchange] = Field(default_factory=list, max_length=20)
 
 
 class Insight(BaseModel):
     question: str = Field(..., examples=[
                           "Will I be too cold without a jacket?"])
     wisdom: str = Field(..., examples=["The wind honors only the prepared."])
+    # Seconds the wisdom stays valid; clients fall back to the next full moon
+    expires_in: int | None = Field(None, examples=[86400])
 
 
 @app.post("/", response_model=Insight)
-def seek_cosmic_wisdom(r: Inquery, context: Request):
-    agent = Agent(model=model, system_prompt=(
-        "You are a mystical orb of pondering that people come to for wisdom. You will provide advice or insight that is deep and relfective but must be extremely concise. Sort of like a prophetic magic 8-ball or a chinese fortune cookie. a wise guru giving spiritual guidance"
-    ),
-        callback_handler=None
+@app.post("/ask", response_model=Insight)
+def seek_cosmic_wisdom(r: Inquery, response: Response,
+                       idempotency_key: str | None = Header(None),
+                       x_request_id: str | None = Header(None)):
+    if x_request_id is not None:
+        response.headers["X-Request-ID"] = x_request_id
+        print(f"request {x_request_id}: {r.question!r}")
+    if idempotency_key is None:
+        return ponder(r)
+
+    try:
+        owner, entry = idempotency.begin(idempotency_key, body_hash(r))
+    except KeyError:
+        raise HTTPException(status_code=422,
+                            detail="Idempotency-Key was already used for a different request")
+    if not owner:
+        if not entry.done.wait(timeout=IDEMPOTENCY_WAIT_SECONDS):
+            raise HTTPException(status_code=504,
+                                detail="the original request is still being pondered")
+        if isinstance(entry.error, HTTPException):
+            raise HTTPException(status_code=entry.error.status_code, detail=entry.error.detail)
+        if entry.error is not None:
+            raise HTTPException(status_code=502, detail="the original request failed")
+        return entry.result
+
+    try:
+        retval = ponder(r)
+    except Exception as e:
+        idempotency.fail(idempotency_key, entry, e)
+        raise
+    idempotency.finish(entry, retval)
+    return retval
+
+
+def ponder(r: Inquery) -> Insight:
+    messages = []
+    for ex in r.history:
+        messages.append({"role": "user", "content": [{"text": wrap_question(ex.question)}]})
+        messages.append({"role": "assistant", "content": [{"text": ex.answer}]})
+    agent = Agent(model=model, system_prompt=PERSONAS.get(r.persona, PERSONAS["orb"]) + DELIMITER_RULES,
+        messages=messages, callback_handler=None
     )
-    resp = agent(r.question)
+    resp = agent(wrap_question(r.question))
     retval = Insight(
         question=r.question,
         wisdom=resp.message['content'][0]['text']
     )
-    print(context.headers)
     print(retval)
     return retval
 
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Banner shown to ssh and web sessions before the orb appears, from
+// --banner or --banner-file; nil shows none. It's a text/template over
+// bannerData:
+//
+//	Welcome to {{.Server}}, {{.User}}. {{.Questions}} questions asked so far.
+var bannerTemplate *template.Template
+
+// Name the banner calls the server by, set by --server-name
+var serverName = ""
+
+// How long the banner shows unless a key is pressed first
+const bannerDuration = 8 * time.Second
+
+// What a banner template can use
+type bannerData struct {
+	Server    string
+	User      string
+	Questions int64 // Asked of this orb since it started
+	Sessions  int   // Open now, this one included
+}
+
+// loadBanner parses the banner from a file, or else from text.
+func loadBanner(text, path string) (*template.Template, error) {
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read banner: %w", err)
+		}
+		text = string(data)
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	t, err := template.New("banner").Parse(strings.TrimRight(text, "\n"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse banner: %w", err)
+	}
+	return t, nil
+}
+
+// showBanner fills in the banner for a session that's starting.
+func (m *model) showBanner() {
+	if bannerTemplate == nil {
+		return
+	}
+	var b strings.Builder
+	err := bannerTemplate.Execute(&b, bannerData{
+		Server:    serverName,
+		User:      m.user,
+		Questions: metricQuestions.Value(),
+		Sessions:  activeSessions.count(),
+	})
+	if err != nil {
+		m.logger.Error("rendering banner", "err", err)
+		return
+	}
+	m.banner = b.String()
+	m.bannerUntil = time.Now().Add(bannerDuration)
+}
+
+// renderBanner draws the banner in the header's gradient, centered where
+// the orb will be.
+func renderBanner(banner string, palette []lipgloss.Color, frame, width, height int, newStyle func() lipgloss.Style) string {
+	var lines []string
+	for _, line := range strings.Split(banner, "\n") {
+		if line == "" {
+			lines = append(lines, "")
+			continue
+		}
+		lines = append(lines, applyGradient(line, palette, frame, newStyle))
+	}
+	hint := newStyle().Foreground(lipgloss.Color("#626262")).Render("Press any key to enter.")
+	body := lipgloss.JoinVertical(lipgloss.Center, lipgloss.JoinVertical(lipgloss.Left, lines...), "", hint)
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, body)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// One line of batch output
+type batchRecord struct {
+	Line      int    `json:"line"`
+	Question  string `json:"question"`
+	Wisdom    string `json:"wisdom,omitempty"`
+	Error     string `json:"error,omitempty"`
+	RequestID string `json:"request_id"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// runBatch asks every line of a file and writes the answers as JSONL:
+//
+//	orb batch questions.txt --out answers.jsonl
+func runBatch(args []string) {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	out := fs.String("out", "", "file to write answers to as JSONL (default stdout)")
+	concurrency := fs.Int("concurrency", 4, "questions asked at the same time")
+	rate := fs.Float64("rate", 2, "most questions started per second (0 for no limit)")
+	persona := fs.String("persona", "", "persona to answer in")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: orb batch questions.txt [flags]")
+		fs.PrintDefaults()
+	}
+	// Flags may come before or after the questions file
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := fs.Arg(0)
+	fs.Parse(fs.Args()[1:])
+
+	if *persona != "" && !validPersona(*persona) {
+		fmt.Fprintf(os.Stderr, "unknown persona %q\n", *persona)
+		os.Exit(2)
+	}
+	questions, err := readQuestions(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create output: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	allowEndpointHosts(wisdomEndpoint)
+	failed := askBatch(questions, *persona, *concurrency, *rate, json.NewEncoder(w))
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// A question and the line it came from
+type batchQuestion struct {
+	line     int
+	question string
+}
+
+// readQuestions reads one question per line, skipping blank lines.
+func readQuestions(path string) ([]batchQuestion, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open questions: %w", err)
+	}
+	defer f.Close()
+
+	var questions []batchQuestion
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		if q := strings.TrimSpace(scanner.Text()); q != "" {
+			questions = append(questions, batchQuestion{line: n, question: q})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read questions: %w", err)
+	}
+	return questions, nil
+}
+
+// askBatch asks the questions with a pool of workers, writing each answer
+// as it arrives and keeping a progress line on stderr. Workers beyond the
+// provider's --max-concurrent cap wait their turn. It returns how many
+// questions failed.
+func askBatch(questions []batchQuestion, persona string, concurrency int, rate float64, enc *json.Encoder) int {
+	jobs := make(chan batchQuestion)
+	go func() {
+		defer close(jobs)
+		var throttle <-chan time.Time
+		if rate > 0 {
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+			defer ticker.Stop()
+			throttle = ticker.C
+		}
+		for i, q := range questions {
+			if throttle != nil && i > 0 {
+				<-throttle
+			}
+			jobs <- q
+		}
+	}()
+
+	var mu sync.Mutex
+	done, failed := 0, 0
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < max(concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for q := range jobs {
+				rec := askOne(q, persona)
+
+				mu.Lock()
+				done++
+				if rec.Error != "" {
+					failed++
+				}
+				if err := enc.Encode(rec); err != nil {
+					fmt.Fprintf(os.Stderr, "\nfailed to write answer: %v\n", err)
+				}
+				fmt.Fprintf(os.Stderr, "\r%d/%d answered, %d failed, %.1f/s",
+					done, len(questions), failed, float64(done)/time.Since(start).Seconds())
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	fmt.Fprintln(os.Stderr)
+	return failed
+}
+
+func askOne(q batchQuestion, persona string) batchRecord {
+	rec := batchRecord{Line: q.line, Question: q.question, RequestID: newRequestID()}
+	ctx := withAsker(withRequest(context.Background(), rec.RequestID, persona), "batch")
+	asked := time.Now()
+	answer, err := wisdom.GetAnswer(ctx, q.question)
+	rec.LatencyMs = time.Since(asked).Milliseconds()
+	// Answers end up in terminals, so they mustn't carry escape sequences
+	if err != nil {
+		rec.Error, _ = stripControl(err.Error())
+	} else {
+		rec.Wisdom, _ = stripControl(answer)
+	}
+	return rec
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Terminals lay text out left to right in the order it's written, so Arabic
+// and Hebrew come out backwards. The orb puts right-to-left text into visual
+// order itself, following a simplified form of the Unicode bidirectional
+// algorithm: strong letters, numbers and the neutrals between them.
+
+// Bidirectional class of a character, simplified
+type bidiClass int
+
+const (
+	bidiNeutral bidiClass = iota // Spaces and punctuation, which take their neighbors' direction
+	bidiLTR
+	bidiRTL
+	bidiNumber // Digits, which run left to right even inside right-to-left text
+)
+
+func bidiClassOf(r rune) bidiClass {
+	switch {
+	case unicode.IsDigit(r):
+		return bidiNumber
+	case unicode.In(r, unicode.Hebrew, unicode.Arabic, unicode.Syriac, unicode.Thaana, unicode.Nko):
+		return bidiRTL
+	case unicode.IsLetter(r):
+		return bidiLTR
+	}
+	return bidiNeutral
+}
+
+// hasRTL reports whether s contains any right-to-left letters.
+func hasRTL(s string) bool {
+	for _, r := range s {
+		if bidiClassOf(r) == bidiRTL {
+			return true
+		}
+	}
+	return false
+}
+
+// paragraphRTL reports whether s reads right to left, judged by its first
+// letter as the Unicode algorithm does.
+func paragraphRTL(s string) bool {
+	for _, r := range s {
+		switch bidiClassOf(r) {
+		case bidiRTL:
+			return true
+		case bidiLTR:
+			return false
+		}
+	}
+	return false
+}
+
+// graphemes splits s into characters with their combining marks (Arabic
+// vowel signs, Hebrew points), which must stay after their letter when the
+// text is reordered.
+func graphemes(s string) []string {
+	var clusters []string
+	for _, r := range s {
+		joins := unicode.In(r, unicode.Mn, unicode.Me) || r == '\u200c' || r == '\u200d'
+		if joins && len(clusters) > 0 {
+			clusters[len(clusters)-1] += string(r)
+			continue
+		}
+		clusters = append(clusters, string(r))
+	}
+	return clusters
+}
+
+// bidiLevels resolves the embedding level of each cluster: even levels run
+// left to right, odd ones right to left.
+func bidiLevels(clusters []string, rtl bool) []int {
+	base := bidiLTR
+	if rtl {
+		base = bidiRTL
+	}
+	classes := make([]bidiClass, len(clusters))
+	for i, c := range clusters {
+		classes[i] = bidiClassOf([]rune(c)[0])
+	}
+	// A lone separator between digits belongs to the number, as in 3.14
+	for i := 1; i+1 < len(classes); i++ {
+		if classes[i] == bidiNeutral && strings.ContainsAny(clusters[i], ".,:") &&
+			classes[i-1] == bidiNumber && classes[i+1] == bidiNumber {
+			classes[i] = bidiNumber
+		}
+	}
+	// Numbers after left-to-right letters are part of that text
+	last := base
+	for i, class := range classes {
+		switch {
+		case class == bidiNumber && last == bidiLTR:
+			classes[i] = bidiLTR
+		case class == bidiLTR || class == bidiRTL:
+			last = class
+		}
+	}
+	// Neutrals between letters of one direction take it; others the base's
+	strong := func(i int) bidiClass {
+		if i < 0 || i >= len(classes) {
+			return base
+		}
+		if classes[i] == bidiNumber {
+			return bidiRTL
+		}
+		return classes[i]
+	}
+	for i := 0; i < len(classes); {
+		if classes[i] != bidiNeutral {
+			i++
+			continue
+		}
+		j := i
+		for j < len(classes) && classes[j] == bidiNeutral {
+			j++
+		}
+		dir := base
+		if before, after := strong(i-1), strong(j); before == after {
+			dir = before
+		}
+		for k := i; k < j; k++ {
+			classes[k] = dir
+		}
+		i = j
+	}
+
+	levels := make([]int, len(classes))
+	for i, class := range classes {
+		switch {
+		case class == bidiNumber:
+			levels[i] = 2
+		case rtl && class == bidiLTR:
+			levels[i] = 2
+		case class == bidiRTL:
+			levels[i] = 1
+		}
+	}
+	// Trailing spaces stay at the line's end
+	for i := len(clusters) - 1; i >= 0 && strings.TrimSpace(clusters[i]) == ""; i-- {
+		levels[i] = 0
+		if rtl {
+			levels[i] = 1
+		}
+	}
+	return levels
+}
+
+// bidiOrder returns, for each position on screen from the left, the index
+// of the cluster shown there.
+func bidiOrder(levels []int) []int {
+	order := make([]int, len(levels))
+	highest, lowestOdd := 0, 3
+	for i, level := range levels {
+		order[i] = i
+		highest = max(highest, level)
+		if level%2 == 1 {
+			lowestOdd = min(lowestOdd, level)
+		}
+	}
+	for level := highest; level >= lowestOdd; level-- {
+		for i := 0; i < len(order); {
+			if levels[order[i]] < level {
+				i++
+				continue
+			}
+			j := i
+			for j < len(order) && levels[order[j]] >= level {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				order[a], order[b] = order[b], order[a]
+			}
+			i = j
+		}
+	}
+	return order
+}
+
+// Brackets and quotes that face the other way in right-to-left text
+var bidiMirrors = map[string]string{
+	"(": ")", ")": "(", "[": "]", "]": "[", "{": "}", "}": "{",
+	"<": ">", ">": "<", "«": "»", "»": "«",
+}
+
+// visualClusters reorders clusters for display, mirroring brackets in
+// right-to-left runs.
+func visualClusters(clusters []string, levels []int) []string {
+	visual := make([]string, len(clusters))
+	for k, i := range bidiOrder(levels) {
+		visual[k] = clusters[i]
+		if mirrored, ok := bidiMirrors[clusters[i]]; ok && levels[i]%2 == 1 {
+			visual[k] = mirrored
+		}
+	}
+	return visual
+}
+
+// visualLine puts one line of text into the order a terminal should draw it.
+func visualLine(line string, rtl bool) string {
+	if !hasRTL(line) {
+		return line
+	}
+	clusters := graphemes(line)
+	return strings.Join(visualClusters(clusters, bidiLevels(clusters, rtl)), "")
+}
+
+// wrapWords breaks text into lines at most width cells wide, splitting
+// words longer than a line.
+func wrapWords(text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		for lipgloss.Width(word) > width {
+			cut := ""
+			for _, c := range graphemes(word) {
+				if lipgloss.Width(cut+c) > width {
+					break
+				}
+				cut += c
+			}
+			if line != "" {
+				lines, line = append(lines, line), ""
+			}
+			lines, word = append(lines, cut), strings.TrimPrefix(word, cut)
+		}
+		switch {
+		case line == "":
+			line = word
+		case lipgloss.Width(line+" "+word) <= width:
+			line += " " + word
+		default:
+			lines, line = append(lines, line), word
+		}
+	}
+	return append(lines, line)
+}
+
+// bidiText lays out text containing right-to-left paragraphs for a box
+// width cells wide: wrapped in reading order, then each line reordered and
+// right-to-left paragraphs aligned right. Text without any comes back as is.
+func bidiText(text string, width int) string {
+	if !hasRTL(text) || width < 1 {
+		return text
+	}
+	var out []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		rtl := paragraphRTL(paragraph)
+		for _, line := range wrapWords(paragraph, width) {
+			line = visualLine(line, rtl)
+			if rtl {
+				line = strings.Repeat(" ", max(width-lipgloss.Width(line), 0)) + line
+			}
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// inputView renders the question being typed, in visual order when it
+// holds right-to-left text, with the cursor on the character it's at.
+func (m model) inputView() string {
+	value := m.textInput.Value()
+	if !hasRTL(value) {
+		return m.textInput.View()
+	}
+	rtl := paragraphRTL(value)
+
+	// Keep the cursor in view when the question is wider than the box
+	runes := []rune(value)
+	pos := m.textInput.Position()
+	start := max(0, pos-m.textInput.Width+1)
+	end := min(len(runes), start+m.textInput.Width)
+	clusters := graphemes(string(runes[start:end]))
+
+	cursorAt, offset := len(clusters), start
+	for i, c := range clusters {
+		if offset >= pos {
+			cursorAt = i
+			break
+		}
+		offset += len([]rune(c))
+	}
+
+	levels := bidiLevels(clusters, rtl)
+	cursor := m.textInput.Cursor
+	cursor.TextStyle = m.textInput.TextStyle
+	visual := visualClusters(clusters, levels)
+	var b strings.Builder
+	if cursorAt == len(clusters) && rtl {
+		cursor.SetChar(" ")
+		b.WriteString(cursor.View())
+	}
+	for k, i := range bidiOrder(levels) {
+		c := visual[k]
+		if i == cursorAt {
+			cursor.SetChar(c)
+			b.WriteString(cursor.View())
+		} else {
+			b.WriteString(m.textInput.TextStyle.Render(c))
+		}
+	}
+	if cursorAt == len(clusters) && !rtl {
+		cursor.SetChar(" ")
+		b.WriteString(cursor.View())
+	}
+	return b.String()
+}
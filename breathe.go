@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// One step of a breathing pattern
+type breathPhase struct {
+	label   string
+	seconds int
+	from    float64 // Orb scale at the start of the phase
+	to      float64 // Orb scale at the end of the phase
+}
+
+// Breathing patterns available to /breathe
+var breathPatterns = map[string][]breathPhase{
+	"478": {
+		{"breathe in", 4, 0.7, 1.0},
+		{"hold", 7, 1.0, 1.0},
+		{"breathe out", 8, 1.0, 0.7},
+	},
+	"box": {
+		{"breathe in", 4, 0.7, 1.0},
+		{"hold", 4, 1.0, 1.0},
+		{"breathe out", 4, 1.0, 0.7},
+		{"hold", 4, 0.7, 0.7},
+	},
+}
+
+// Parting words once a meditation ends
+var aphorisms = []string{
+	"The breath you just took was the only one that mattered.",
+	"Stillness is not the absence of motion, but the presence of attention.",
+	"You came here restless. Leave here ready.",
+	"The tide goes out so that it may return.",
+	"What you seek was waiting in the pause between breaths.",
+}
+
+// Whether a closing aphorism is shown when a meditation ends
+var showAphorism = true
+
+// A running meditation session
+type meditation struct {
+	pattern []breathPhase
+	started time.Time
+	ends    time.Time
+}
+
+// parseBreathe reads "/breathe [pattern] [duration]", defaulting to 4-7-8 for five minutes.
+func parseBreathe(input string) (meditation, error) {
+	name, duration := "478", 5*time.Minute
+	for _, arg := range strings.Fields(strings.TrimPrefix(input, "/breathe")) {
+		if _, ok := breathPatterns[arg]; ok {
+			name = arg
+			continue
+		}
+		d, err := time.ParseDuration(arg)
+		if err != nil || d <= 0 {
+			return meditation{}, fmt.Errorf("unknown breathing option %q", arg)
+		}
+		duration = d
+	}
+	now := time.Now()
+	return meditation{pattern: breathPatterns[name], started: now, ends: now.Add(duration)}, nil
+}
+
+func (md meditation) cycleSeconds() int {
+	total := 0
+	for _, p := range md.pattern {
+		total += p.seconds
+	}
+	return total
+}
+
+// phase returns the current step and how far into it we are, from 0 to 1.
+func (md meditation) phase(now time.Time) (breathPhase, float64) {
+	elapsed := now.Sub(md.started).Seconds()
+	into := elapsed - float64(int(elapsed)/md.cycleSeconds()*md.cycleSeconds())
+	for _, p := range md.pattern {
+		if into < float64(p.seconds) {
+			return p, into / float64(p.seconds)
+		}
+		into -= float64(p.seconds)
+	}
+	last := md.pattern[len(md.pattern)-1]
+	return last, 1
+}
+
+// scale is how large the orb should be drawn right now.
+func (md meditation) scale(now time.Time) float64 {
+	p, progress := md.phase(now)
+	return lerp(p.from, p.to, ease(progress))
+}
+
+// status is the text shown in place of the prompt.
+func (md meditation) status(now time.Time) string {
+	p, progress := md.phase(now)
+	left := p.seconds - int(progress*float64(p.seconds))
+	remaining := md.ends.Sub(now).Round(time.Second)
+	return fmt.Sprintf("%s... %d\n\n%s remaining [esc to stop]", p.label, left, remaining)
+}
+
+func (md meditation) done(now time.Time) bool {
+	return !now.Before(md.ends)
+}
+
+func closingAphorism() string {
+	return aphorisms[rand.Intn(len(aphorisms))]
+}
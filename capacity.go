@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Share of the host's CPUs the orb's animation may take across all sessions
+const renderBudget = 0.5
+
+// Frame rates the tuner chooses between, smoothest first
+var tunedFrameRates = []int{20, 15, 10, 5}
+
+// Sessions the tuner wants room for before it settles for a lower frame rate
+const tunedSessionTarget = 50
+
+// How long the startup benchmark draws frames for
+const benchmarkDuration = 200 * time.Millisecond
+
+// Whether the tuner's estimates replace --animation-fps and --max-sessions
+// when they weren't set, set by --auto-tune
+var autoTune = false
+
+// What the host can afford to animate
+type capacity struct {
+	frameCost   time.Duration // Time to draw one frame of a session
+	fps         int
+	maxSessions int
+}
+
+// benchmarkFrame measures how long a frame of a typical ssh session takes
+// to draw.
+func benchmarkFrame() time.Duration {
+	renderer := lipgloss.NewRenderer(io.Discard)
+	renderer.SetColorProfile(termenv.TrueColor)
+	m := initialModel()
+	m.useRenderer(renderer)
+	m.width, m.height = 100, 40
+	m.View() // Warm up caches
+
+	start := time.Now()
+	frames := 0
+	for time.Since(start) < benchmarkDuration || frames < 5 {
+		m.frame++
+		m.View()
+		frames++
+	}
+	return time.Since(start) / time.Duration(frames)
+}
+
+// estimateCapacity works out how many sessions cpus can animate at fps
+// within the render budget. Without an fps it picks the smoothest frame
+// rate with room for tunedSessionTarget sessions, or else the lowest.
+func estimateCapacity(frameCost time.Duration, cpus, fps int) capacity {
+	budget := renderBudget * float64(cpus) // CPU seconds per second
+	sessions := func(fps int) int {
+		return max(int(budget/(float64(fps)*frameCost.Seconds())), 1)
+	}
+	if fps > 0 {
+		return capacity{frameCost: frameCost, fps: fps, maxSessions: sessions(fps)}
+	}
+	c := capacity{frameCost: frameCost}
+	for _, fps := range tunedFrameRates {
+		c.fps, c.maxSessions = fps, sessions(fps)
+		if c.maxSessions >= tunedSessionTarget {
+			break
+		}
+	}
+	return c
+}
+
+// tuneCapacity benchmarks the host and logs what it can afford. With
+// --auto-tune it applies the estimates to the settings not given on the
+// command line, in the environment or in the config file.
+func tuneCapacity(explicit map[string]bool) {
+	fps := 0
+	if explicit["animation-fps"] {
+		fps = animationFPS
+	}
+	c := estimateCapacity(benchmarkFrame(), runtime.NumCPU(), fps)
+	slog.Info("capacity estimated", "frame_cost", c.frameCost.Round(time.Microsecond), "cpus", runtime.NumCPU(),
+		"sessions", c.maxSessions, "fps", c.fps)
+	if !autoTune {
+		slog.Info("capacity: --auto-tune would apply these", "animation_fps", c.fps, "max_sessions", c.maxSessions)
+		return
+	}
+	if !explicit["animation-fps"] {
+		animationFPS = c.fps
+	}
+	if !explicit["max-sessions"] {
+		maxSessions = c.maxSessions
+	}
+	slog.Info("capacity applied", "animation_fps", animationFPS, "max_sessions", maxSessions)
+}
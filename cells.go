@@ -0,0 +1,145 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Styled cells a session keeps before starting its cache afresh; themes
+// crossfading through many colors would otherwise grow it without end
+const maxStyledCells = 4096
+
+// One cell of an orb as it's drawn; the zero cell lies outside the orb
+type orbCell struct {
+	color lipgloss.Color
+	glyph string
+}
+
+// Which of the orbs a row is drawn from
+const (
+	mainOrb = iota
+	leftSatellite
+	rightSatellite
+)
+
+// What a frame of one orb is drawn from
+type orbFrame struct {
+	orb                          int
+	width, height, radius, frame int
+	palette                      []lipgloss.Color
+	rim                          lipgloss.Color
+	ring                         rimRing
+}
+
+// A stretch of a row as last drawn
+type orbSpan struct {
+	cells []orbCell
+	text  string
+	drawn int // The frame it was last part of
+}
+
+// Where in which orb a span lies
+type spanKey struct {
+	orb, y, from, to int
+}
+
+// cellBuffer keeps a session's orbs as they were last drawn. Each cell is
+// styled once per color and glyph, and rows whose cells are unchanged since
+// the last frame are reused whole, so still parts of the orb cost little.
+type cellBuffer struct {
+	mu       sync.Mutex
+	profile  termenv.Profile
+	newStyle func() lipgloss.Style
+	styled   map[orbCell]string
+	spans    map[spanKey]*orbSpan
+	frames   int
+	scratch  []orbCell
+}
+
+func newCellBuffer() *cellBuffer {
+	return &cellBuffer{styled: map[orbCell]string{}, spans: map[spanKey]*orbSpan{}}
+}
+
+// begin starts a frame drawn for profile with newStyle. Spans left out of
+// the last frame, such as those beside a text box since resized, are
+// dropped.
+func (b *cellBuffer) begin(profile termenv.Profile, newStyle func() lipgloss.Style) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if profile != b.profile || len(b.styled) > maxStyledCells {
+		clear(b.styled)
+		clear(b.spans)
+	}
+	b.profile, b.newStyle = profile, newStyle
+	for key, span := range b.spans {
+		if span.drawn < b.frames {
+			delete(b.spans, key)
+		}
+	}
+	b.frames++
+}
+
+// span draws row y of an orb from x up to but not including to.
+func (b *cellBuffer) span(o orbFrame, y, from, to int) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if to <= from {
+		return ""
+	}
+	b.scratch = b.scratch[:0]
+	for x := from; x < to; x++ {
+		b.scratch = append(b.scratch, b.cell(o, x, y))
+	}
+	key := spanKey{o.orb, y, from, to}
+	span := b.spans[key]
+	if span == nil {
+		span = &orbSpan{}
+		b.spans[key] = span
+	}
+	span.drawn = b.frames
+	if span.text != "" && slices.Equal(span.cells, b.scratch) {
+		return span.text
+	}
+
+	var text strings.Builder
+	for _, c := range b.scratch {
+		text.WriteString(b.style(c))
+	}
+	span.cells = append(span.cells[:0], b.scratch...)
+	span.text = text.String()
+	return span.text
+}
+
+// cell works out how a cell of an orb looks. Terminals with 16 colors or
+// none can't tell the swirl's colors apart, so it's shaded with glyphs.
+func (b *cellBuffer) cell(o orbFrame, x, y int) orbCell {
+	color, ok := orbPixelColor(x, y, o.width, o.height, o.radius, o.frame, o.palette, o.rim, o.ring)
+	switch {
+	case !ok:
+		return orbCell{}
+	case b.profile == termenv.Ascii || b.profile == termenv.ANSI:
+		return orbCell{color, shade(color, o.palette)}
+	}
+	return orbCell{color, "█"}
+}
+
+// style renders a cell, styling each color and glyph only the first time
+// it's seen.
+func (b *cellBuffer) style(c orbCell) string {
+	switch {
+	case c.glyph == "":
+		return " "
+	case b.profile == termenv.Ascii:
+		return c.glyph
+	}
+	if s, ok := b.styled[c]; ok {
+		return s
+	}
+	s := b.newStyle().Foreground(c.color).SetString(c.glyph).String()
+	b.styled[c] = s
+	return s
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Seconds of the orb kept for clips in local mode, set by --clip-seconds
+var clipSeconds = 6
+
+// Frames per second kept for clips, well under the animation rate so the
+// buffer stays small
+const clipFPS = 10
+
+// A rendered screen and when it was shown
+type recordedFrame struct {
+	at   time.Time
+	view string
+}
+
+// frameRecorder keeps the last few seconds of rendered frames in a ring
+// buffer, so the moment an answer arrives can be saved after the fact.
+type frameRecorder struct {
+	frames []recordedFrame
+	next   int
+	full   bool
+}
+
+func newFrameRecorder(seconds int) *frameRecorder {
+	return &frameRecorder{frames: make([]recordedFrame, seconds*clipFPS)}
+}
+
+// add records a frame unless the last one was too recent.
+func (r *frameRecorder) add(now time.Time, view string) {
+	last := r.frames[(r.next+len(r.frames)-1)%len(r.frames)]
+	if now.Sub(last.at) < time.Second/clipFPS || view == last.view {
+		return
+	}
+	r.frames[r.next] = recordedFrame{at: now, view: view}
+	r.next = (r.next + 1) % len(r.frames)
+	r.full = r.full || r.next == 0
+}
+
+// clip returns the buffered frames, oldest first.
+func (r *frameRecorder) clip() []recordedFrame {
+	if !r.full {
+		return append([]recordedFrame(nil), r.frames[:r.next]...)
+	}
+	return append(append([]recordedFrame(nil), r.frames[r.next:]...), r.frames[:r.next]...)
+}
+
+// writeCast saves frames as an asciinema (v2) recording.
+func writeCast(path string, frames []recordedFrame, width, height int) error {
+	if len(frames) == 0 {
+		return fmt.Errorf("nothing recorded yet")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create clip: %w", err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	start := frames[0].at
+	header := map[string]any{
+		"version":   2,
+		"width":     width,
+		"height":    height,
+		"timestamp": start.Unix(),
+		"title":     "The orb has spoken",
+	}
+	if err := enc.Encode(header); err != nil {
+		return fmt.Errorf("failed to write clip: %w", err)
+	}
+	for _, fr := range frames {
+		// Redraw in place, clearing what the previous frame left behind
+		screen := "\x1b[H" + strings.ReplaceAll(fr.view, "\n", "\x1b[K\r\n") + "\x1b[K\x1b[J"
+		event := []any{fr.at.Sub(start).Seconds(), "o", screen}
+		if err := enc.Encode(event); err != nil {
+			return fmt.Errorf("failed to write clip: %w", err)
+		}
+	}
+	return nil
+}
+
+// Reports where a clip was saved
+type clipSavedMsg struct {
+	path string
+	err  error
+}
+
+// saveClipCmd writes the recorded frames to a new .cast file in the working
+// directory.
+func saveClipCmd(frames []recordedFrame, width, height int) tea.Cmd {
+	return func() tea.Msg {
+		path := "orb-" + time.Now().Format("20060102-150405") + ".cast"
+		return clipSavedMsg{path: path, err: writeCast(path, frames, width, height)}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Colors ssh sessions are drawn with, set by --colors; auto goes by the
+// client's TERM and COLORTERM. A client can pick its own with ORB_COLORS.
+var sessionColors = "auto"
+
+// Color profiles --colors and ORB_COLORS can name
+var colorProfiles = map[string]termenv.Profile{
+	"truecolor": termenv.TrueColor,
+	"256":       termenv.ANSI256,
+	"16":        termenv.ANSI,
+	"none":      termenv.Ascii,
+}
+
+// parseColors checks a --colors value.
+func parseColors(name string) error {
+	if _, ok := colorProfiles[name]; ok || name == "auto" {
+		return nil
+	}
+	names := slices.Sorted(maps.Keys(colorProfiles))
+	return fmt.Errorf("unknown colors %q, want auto, %s", name, strings.Join(names, ", "))
+}
+
+// sessionProfile picks the color profile for a session whose terminal was
+// detected as detected. NO_COLOR wins over everything.
+func sessionProfile(detected termenv.Profile, env map[string]string) termenv.Profile {
+	if env["NO_COLOR"] != "" {
+		// https://no-color.org
+		return termenv.Ascii
+	}
+	if p, ok := colorProfiles[env["ORB_COLORS"]]; ok {
+		return p
+	}
+	if p, ok := colorProfiles[sessionColors]; ok {
+		return p
+	}
+	return detected
+}
+
+// Glyphs the swirl is shaded with where the terminal has too few colors to
+// show it, one for each palette color
+var swirlShades = []string{"░", "▒", "▓", "▒", "░"}
+
+// colorProfile returns the colors the session's terminal shows.
+func (m model) colorProfile() termenv.Profile {
+	if m.renderer != nil {
+		return m.renderer.ColorProfile()
+	}
+	return lipgloss.ColorProfile()
+}
+
+// shade returns the glyph drawing a palette color; the rim and anything
+// else off the palette is solid.
+func shade(color lipgloss.Color, palette []lipgloss.Color) string {
+	if i := slices.Index(palette, color); i >= 0 && i < len(swirlShades) {
+		return swirlShades[i]
+	}
+	return "█"
+}
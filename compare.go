@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Wisdom API questions are also sent to, set by --compare
+var compareEndpoint = ""
+
+// comparisonsPath is where A/B preferences are recorded, beside the history.
+func comparisonsPath() string {
+	return filepath.Join(filepath.Dir(historyPath), "comparisons.jsonl")
+}
+
+// A message with answers from both backends
+type comparisonMsg struct {
+	answers [2]string
+}
+
+// getComparisonCmd asks the primary and the comparison backend at the same time.
+func getComparisonCmd(ctx context.Context, question, persona, requestID string) tea.Cmd {
+	return func() tea.Msg {
+		contenders := [2]WisdomProvider{wisdom, &ponderProvider{endpoint: compareEndpoint}}
+		var msg comparisonMsg
+		var wg sync.WaitGroup
+		for i, provider := range contenders {
+			wg.Add(1)
+			go func(i int, provider WisdomProvider) {
+				defer wg.Done()
+				ctx := withRequest(ctx, fmt.Sprintf("%s-%c", requestID, 'a'+i), persona)
+				answer, err := provider.GetAnswer(ctx, question)
+				if err != nil {
+					answer = fmt.Sprintf("(no answer: %v)", err)
+				}
+				msg.answers[i] = answer
+			}(i, provider)
+		}
+		wg.Wait()
+		if ctx.Err() != nil {
+			return interruptedMsg{}
+		}
+		return msg
+	}
+}
+
+// One judged comparison, as written to comparisonsPath()
+type comparisonRecord struct {
+	Time     time.Time `json:"time"`
+	Question string    `json:"question"`
+	A        string    `json:"a"`
+	B        string    `json:"b"`
+	Sources  [2]string `json:"sources"`
+	Choice   string    `json:"choice"`
+}
+
+var comparisonsMu sync.Mutex
+
+// recordPreference appends the user's pick between answers A and B.
+func recordPreference(question string, answers [2]string, choice string) error {
+	rec := comparisonRecord{
+		Time:     time.Now(),
+		Question: question,
+		A:        answers[0],
+		B:        answers[1],
+		Sources:  [2]string{wisdomName, compareEndpoint},
+		Choice:   choice,
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode comparison: %w", err)
+	}
+
+	comparisonsMu.Lock()
+	defer comparisonsMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(comparisonsPath()), 0755); err != nil {
+		return fmt.Errorf("failed to create comparisons directory: %w", err)
+	}
+	f, err := os.OpenFile(comparisonsPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open comparisons file: %w", err)
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// renderComparison lays the two answers out side by side.
+func renderComparison(answers [2]string, choice string, width int, newStyle func() lipgloss.Style) string {
+	colWidth := width/2 - 4
+	var cols []string
+	for i, label := range []string{"A", "B"} {
+		labelStyle := newStyle().Bold(true).Foreground(lipgloss.Color("240"))
+		if choice == label {
+			labelStyle = labelStyle.Foreground(lipgloss.Color("155"))
+		}
+		body := newStyle().Width(colWidth).Render(answers[i])
+		cols = append(cols, newStyle().Padding(0, 1).Render(
+			lipgloss.JoinVertical(lipgloss.Center, labelStyle.Render(label), body),
+		))
+	}
+
+	hint := "Which rings truer? [a/b]"
+	if choice != "" {
+		hint = "Ask another question [enter]"
+	}
+	hintView := newStyle().Padding(1, 2, 0).Foreground(lipgloss.Color("240")).Render(hint)
+	return lipgloss.JoinVertical(lipgloss.Center,
+		newStyle().Padding(1, 0, 0).Render(lipgloss.JoinHorizontal(lipgloss.Top, cols...)),
+		hintView,
+	)
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultConfigPath returns $XDG_CONFIG_HOME/orb/config.toml, falling back
+// to ~/.config when XDG_CONFIG_HOME isn't set.
+func defaultConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "orb", "config.toml")
+}
+
+// parseConfig reads the subset of TOML the config file needs: [sections],
+// key = value pairs with string, number, boolean and string array values,
+// and # comments. Keys come back as "section.key".
+func parseConfig(data string) (map[string]string, error) {
+	values := make(map[string]string)
+	section := ""
+	for n, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", n+1)
+		}
+		key = strings.TrimSpace(key)
+		if section != "" {
+			key = section + "." + key
+		}
+		value, err := parseConfigValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// stripComment drops a trailing # comment that isn't inside a string.
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseConfigValue turns a TOML value into the string a flag would take.
+// Arrays become comma separated lists.
+func parseConfigValue(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, "["):
+		if !strings.HasSuffix(raw, "]") {
+			return "", errors.New("unterminated array")
+		}
+		var items []string
+		for _, item := range splitArray(raw[1 : len(raw)-1]) {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			v, err := parseConfigValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, v)
+		}
+		return strings.Join(items, ","), nil
+	case strings.HasPrefix(raw, `"`):
+		return strconv.Unquote(raw)
+	case strings.HasPrefix(raw, "'"):
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") {
+			return "", errors.New("unterminated string")
+		}
+		return raw[1 : len(raw)-1], nil
+	case raw == "true" || raw == "false":
+		return raw, nil
+	default:
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return "", fmt.Errorf("unsupported value %s", raw)
+		}
+		return raw, nil
+	}
+}
+
+// splitArray splits array items on commas that aren't inside a string.
+func splitArray(inner string) []string {
+	var items []string
+	var quote rune
+	start := 0
+	for i, r := range inner {
+		switch {
+		case quote != 0 && r == quote && !(quote == '"' && escaped(inner[:i])):
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == ',':
+			items = append(items, inner[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, inner[start:])
+}
+
+// escaped reports whether s ends in an odd number of backslashes.
+func escaped(s string) bool {
+	n := 0
+	for n < len(s) && s[len(s)-1-n] == '\\' {
+		n++
+	}
+	return n%2 == 1
+}
+
+// readConfig parses a config file. A missing file is only an error when
+// required; otherwise it yields no settings.
+func readConfig(path string, required bool) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	values, err := parseConfig(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// settingFlag names the flag a setting maps to: [ssh] address is --ssh-address.
+func settingFlag(key string) string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(key)
+}
+
+// Environment variables that stand in for flags
+var envFlags = map[string]string{
+	"ORB_ENDPOINT": "endpoint",
+	"ORB_LISTEN":   "listen",
+	"ORB_HOST_KEY": "host-key",
+}
+
+// Flags that are other names for the same setting
+var flagAliases = map[string]string{
+	"listen":   "ssh-address",
+	"host-key": "ssh-host-key",
+	"max-fps":  "animation-fps",
+}
+
+// explicitFlags returns the flags already set, counting a flag as set when
+// its alias is.
+func explicitFlags(flags *flag.FlagSet) map[string]bool {
+	explicit := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	for alias, name := range flagAliases {
+		if explicit[alias] || explicit[name] {
+			explicit[alias], explicit[name] = true, true
+		}
+	}
+	return explicit
+}
+
+// applyEnv sets flags from their environment variables unless they were
+// given on the command line. Run it before applyConfig so the environment
+// wins over the config file.
+func applyEnv(flags *flag.FlagSet) error {
+	explicit := explicitFlags(flags)
+	for env, name := range envFlags {
+		value := os.Getenv(env)
+		if value == "" || explicit[name] || flags.Lookup(name) == nil {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("invalid %s: %w", env, err)
+		}
+	}
+	return nil
+}
+
+// applyConfig fills in flags from config file settings. Each setting is
+// named after its flag, so [ssh] address sets --ssh-address; flags given on
+// the command line win over the file.
+func applyConfig(flags *flag.FlagSet, path string, values map[string]string) error {
+	explicit := explicitFlags(flags)
+	for key, value := range values {
+		name := settingFlag(key)
+		if flags.Lookup(name) == nil {
+			return fmt.Errorf("unknown setting %q in %s", key, path)
+		}
+		if explicit[name] {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("invalid setting %q in %s: %w", key, path, err)
+		}
+	}
+	return nil
+}
+
+// applySharedConfig applies the settings subcommands like quick and batch
+// share with the orb itself, ignoring the ones only the orb understands.
+func applySharedConfig(path string, values map[string]string) error {
+	flags := flag.NewFlagSet("config", flag.ContinueOnError)
+	flags.StringVar(&wisdomEndpoint, "endpoint", wisdomEndpoint, "")
+	flags.StringVar(&historyPath, "history", historyPath, "")
+	flags.StringVar(&orbTheme, "theme", orbTheme, "")
+	flags.BoolVar(&allowPrivateAddrs, "allow-private-addrs", allowPrivateAddrs, "")
+	flags.StringVar(&ollamaURL, "ollama-url", ollamaURL, "")
+	flags.StringVar(&ollamaModel, "ollama-model", ollamaModel, "")
+	flags.StringVar(&openaiURL, "openai-url", openaiURL, "")
+	flags.StringVar(&openaiModel, "openai-model", openaiModel, "")
+	flags.Float64Var(&openaiTemperature, "openai-temperature", openaiTemperature, "")
+	flags.StringVar(&openaiKeyFile, "openai-key-file", openaiKeyFile, "")
+	flags.StringVar(&openaiSystemPrompt, "openai-system-prompt", openaiSystemPrompt, "")
+	fps := flags.Int("animation-fps", animationFPS, "")
+	limits := flags.String("max-concurrent", "", "")
+	provider := flags.String("provider", defaultProvider, "")
+	offline := flags.Bool("offline", false, "")
+	fortunes := flags.String("fortunes", "", "")
+	routes := flags.String("routes", "", "")
+	rules := flags.String("rules", "", "")
+
+	shared := make(map[string]string)
+	for key, value := range values {
+		if flags.Lookup(settingFlag(key)) != nil {
+			shared[key] = value
+		}
+	}
+	if err := applyEnv(flags); err != nil {
+		return err
+	}
+	if err := applyConfig(flags, path, shared); err != nil {
+		return err
+	}
+	if *fps > 0 {
+		animationFPS = *fps
+	}
+	var err error
+	if providerLimits, err = parseProviderLimits(*limits); err != nil {
+		return err
+	}
+	// Subcommands ask the same provider the orb would
+	return setupWisdom(*provider, *offline, *fortunes, *routes, *rules)
+}
+
+// loadSharedConfig reads the default config file for subcommands, which
+// don't take --config themselves.
+func loadSharedConfig() {
+	path := defaultConfigPath()
+	values, err := readConfig(path, false)
+	if err == nil {
+		err = applySharedConfig(path, values)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
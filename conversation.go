@@ -0,0 +1,38 @@
+package main
+
+import "context"
+
+// Earlier questions and answers sent along for follow-ups, set by
+// --conversation-turns; 0 asks every question afresh
+var conversationTurns = 3
+
+// The most earlier exchanges the wisdom API accepts
+const maxConversationTurns = 20
+
+// One question and the answer it got
+type exchange struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
+
+// converse remembers an exchange, keeping only the latest few.
+func converse(conversation []exchange, question, answer string) []exchange {
+	if conversationTurns <= 0 {
+		return nil
+	}
+	conversation = append(conversation, exchange{Question: question, Answer: answer})
+	if len(conversation) > conversationTurns {
+		conversation = conversation[len(conversation)-conversationTurns:]
+	}
+	return conversation
+}
+
+// withConversation attaches the exchanges leading up to a question to ctx.
+func withConversation(ctx context.Context, conversation []exchange) context.Context {
+	return context.WithValue(ctx, conversationKey, conversation)
+}
+
+func conversationFrom(ctx context.Context) []exchange {
+	conversation, _ := ctx.Value(conversationKey).([]exchange)
+	return conversation
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Color of the cooldown arc drawn on the orb's rim
+const cooldownRingColor = "#FF8C7F"
+
+// How long to rest when a rate limited backend doesn't say, and at most
+const (
+	defaultCooldown = 30 * time.Second
+	maxCooldown     = 5 * time.Minute
+)
+
+// A backend turned the question away for asking too often
+type rateLimitError struct {
+	host       string
+	retryAfter time.Duration
+}
+
+func (e *rateLimitError) Error() string {
+	return fmt.Sprintf("%s is rate limiting, retry after %v", e.host, e.retryAfter)
+}
+
+func (e *rateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// retryAfter reads how long a 429 response asks the client to wait, as
+// seconds or an HTTP date.
+func retryAfter(resp *http.Response, now time.Time) time.Duration {
+	d := defaultCooldown
+	header := resp.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(header); err == nil {
+		d = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(header); err == nil {
+		d = at.Sub(now)
+	}
+	return min(max(d, time.Second), maxCooldown)
+}
+
+// A wait before the orb takes another question, shown as an arc on its rim
+type cooldown struct {
+	started  time.Time
+	duration time.Duration
+}
+
+// remaining is the fraction of the cooldown still to go, from 1 down to 0.
+func (c cooldown) remaining(now time.Time) float64 {
+	left := c.duration - now.Sub(c.started)
+	if left <= 0 {
+		return 0
+	}
+	return float64(left) / float64(c.duration)
+}
+
+func (c cooldown) done(now time.Time) bool {
+	return now.Sub(c.started) >= c.duration
+}
+
+// A lit arc on the orb's rim, clockwise from the top
+type rimRing struct {
+	lit   float64 // Fraction of the rim lit, negative for none
+	color lipgloss.Color
+}
+
+var noRing = rimRing{lit: -1}
+
+// ring is the arc the orb's rim shows: a running focus timer, or else the
+// cooldown depleting.
+func (m model) ring(now time.Time) rimRing {
+	switch {
+	case m.focus != nil:
+		return rimRing{lit: m.focus.remaining(now), color: lipgloss.Color(focusRingColor)}
+	case m.cooldown != nil:
+		return rimRing{lit: m.cooldown.remaining(now), color: lipgloss.Color(cooldownRingColor)}
+	}
+	return noRing
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Bounds on the answer memory, which lives as long as the server does
+const (
+	maxRememberedAnswers = 1000
+	answerMemoryTTL      = 24 * time.Hour
+)
+
+// A remembered answer and when it was given
+type rememberedAnswer struct {
+	answer string
+	at     time.Time
+}
+
+// The last answer each asker got to each question, so a changed prophecy
+// can be noticed
+type answerMemory struct {
+	mu      sync.Mutex
+	answers map[string]rememberedAnswer // Asker and normalized question to answer
+}
+
+var recentAnswers = &answerMemory{answers: make(map[string]rememberedAnswer)}
+
+func normalizeQuestion(q string) string {
+	return strings.ToLower(strings.Join(strings.Fields(q), " "))
+}
+
+// remember stores the answer and returns the previous one if it differed.
+// Answers are only compared for the same asker; an empty asker, who can't
+// be recognized, is never remembered.
+func (a *answerMemory) remember(asker, question, answer string) (previous string, changed bool) {
+	if asker == "" {
+		return "", false
+	}
+	key := asker + "\x00" + normalizeQuestion(question)
+	now := time.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	prev, seen := a.answers[key]
+	if seen && now.Sub(prev.at) > answerMemoryTTL {
+		seen = false
+	}
+	a.answers[key] = rememberedAnswer{answer: answer, at: now}
+	a.evict(now)
+	return prev.answer, seen && prev.answer != answer
+}
+
+// evict drops expired answers, then the oldest ones while over the limit.
+func (a *answerMemory) evict(now time.Time) {
+	if len(a.answers) <= maxRememberedAnswers {
+		return
+	}
+	for key, r := range a.answers {
+		if now.Sub(r.at) > answerMemoryTTL {
+			delete(a.answers, key)
+		}
+	}
+	for len(a.answers) > maxRememberedAnswers {
+		var oldest string
+		for key, r := range a.answers {
+			if oldest == "" || r.at.Before(a.answers[oldest].at) {
+				oldest = key
+			}
+		}
+		delete(a.answers, oldest)
+	}
+}
+
+// One word of a diff
+type diffOp struct {
+	kind byte // '=' kept, '-' removed, '+' added
+	word string
+}
+
+// diffWords computes a word-level diff using the longest common subsequence.
+func diffWords(old, new string) []diffOp {
+	a, b := strings.Fields(old), strings.Fields(new)
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{'=', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// renderDiff shows removed words struck through and added words highlighted.
+func renderDiff(old, new string, width int, newStyle func() lipgloss.Style) string {
+	removed := newStyle().Foreground(lipgloss.Color("#C0504D")).Strikethrough(true)
+	added := newStyle().Foreground(lipgloss.Color("155"))
+
+	var words []string
+	for _, op := range diffWords(old, new) {
+		switch op.kind {
+		case '-':
+			words = append(words, removed.Render(op.word))
+		case '+':
+			words = append(words, added.Render(op.word))
+		default:
+			words = append(words, op.word)
+		}
+	}
+	return newStyle().Width(width).Render(strings.Join(words, " "))
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/muesli/termenv"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// How long the doctor waits on each backend
+const doctorTimeout = 5 * time.Second
+
+// One finding of orb doctor
+type diagnosis struct {
+	status string // "ok", "warn" or "FAIL"
+	check  string
+	detail string
+}
+
+// doctor collects findings for the diagnostic report.
+type doctor struct {
+	findings []diagnosis
+}
+
+func (d *doctor) ok(check, format string, args ...any) {
+	d.findings = append(d.findings, diagnosis{"ok", check, fmt.Sprintf(format, args...)})
+}
+
+func (d *doctor) warn(check, format string, args ...any) {
+	d.findings = append(d.findings, diagnosis{"warn", check, fmt.Sprintf(format, args...)})
+}
+
+func (d *doctor) fail(check, format string, args ...any) {
+	d.findings = append(d.findings, diagnosis{"FAIL", check, fmt.Sprintf(format, args...)})
+}
+
+func (d *doctor) failed() bool {
+	for _, f := range d.findings {
+		if f.status == "FAIL" {
+			return true
+		}
+	}
+	return false
+}
+
+// setting returns the value a flag of the orb ends up with.
+func setting(name string) string {
+	if f := flag.Lookup(name); f != nil {
+		return f.Value.String()
+	}
+	return ""
+}
+
+// runDoctor checks the orb's setup as the flags, environment and config file
+// leave it, prints a diagnostic report and saves it for support threads:
+//
+//	orb --ssh doctor
+//	orb doctor --report /tmp/orb-doctor.txt
+func runDoctor(args []string, configPath string, required bool) {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	report := flags.String("report", "orb-doctor-"+time.Now().Format("20060102-150405")+".txt", "file the diagnostic report is written to")
+	flags.Parse(args)
+
+	var d doctor
+	d.checkConfig(configPath, required)
+	d.checkHostKey(setting("ssh-host-key"))
+	if path := setting("signing-key"); path != "" {
+		d.checkSigningKey(path)
+	}
+	d.checkBackends()
+	d.checkHistory(setting("history"))
+	d.checkTerminal()
+
+	text := doctorHeader() + d.String()
+	fmt.Print(text)
+	if err := os.WriteFile(*report, []byte(text), 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write report: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("\nreport written to %s; attach it when asking for help\n", *report)
+	if d.failed() {
+		os.Exit(1)
+	}
+}
+
+// buildVersion returns the module version and VCS revision the orb was
+// built from.
+func buildVersion() (version, revision string) {
+	version, revision = "(devel)", "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		version = info.Main.Version
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				revision = s.Value
+			}
+		}
+	}
+	return version, revision
+}
+
+// doctorHeader describes the build and platform.
+func doctorHeader() string {
+	version, revision := buildVersion()
+	return fmt.Sprintf("orb doctor, %s\norb %s (revision %s), %s %s/%s\n\n",
+		time.Now().Format(time.RFC3339), version, revision, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
+
+func (d *doctor) String() string {
+	var b strings.Builder
+	for _, f := range d.findings {
+		fmt.Fprintf(&b, "[%-4s] %-15s %s\n", f.status, f.check, f.detail)
+	}
+	return b.String()
+}
+
+// checkConfig applies the environment and config file the way the orb
+// does, so later checks see the settings it would run with.
+func (d *doctor) checkConfig(path string, required bool) {
+	if err := applyEnv(flag.CommandLine); err != nil {
+		d.fail("environment", "%v", err)
+	}
+	values, err := readConfig(path, required)
+	if err != nil {
+		d.fail("config", "%v", err)
+		return
+	}
+	configMacros = takeMacros(values)
+	if err := applyConfig(flag.CommandLine, path, values); err != nil {
+		d.fail("config", "%v", err)
+		return
+	}
+	if values == nil {
+		d.ok("config", "%s not found, using defaults", path)
+		return
+	}
+	d.ok("config", "%s (%d settings)", path, len(values))
+}
+
+// checkKeyFile reports whether a private key exists and only its owner can
+// read it, returning its contents when it's worth checking further.
+func (d *doctor) checkKeyFile(check, path, missing string) []byte {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		d.warn(check, "%s is missing; %s", path, missing)
+		return nil
+	}
+	if err != nil {
+		d.fail(check, "%v", err)
+		return nil
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		d.fail(check, "%s is readable by others (mode %04o); chmod 600 it", path, perm)
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		d.fail(check, "%v", err)
+		return nil
+	}
+	return data
+}
+
+func (d *doctor) checkHostKey(path string) {
+	data := d.checkKeyFile("ssh host key", path, "one is generated when the ssh server starts")
+	if data == nil {
+		return
+	}
+	key, err := gossh.ParsePrivateKey(data)
+	var missing *gossh.PassphraseMissingError
+	switch {
+	case errors.As(err, &missing):
+		d.fail("ssh host key", "%s is protected by a passphrase the server can't supply", path)
+	case err != nil:
+		d.fail("ssh host key", "%s: %v", path, err)
+	default:
+		d.ok("ssh host key", "%s (%s)", path, gossh.FingerprintSHA256(key.PublicKey()))
+	}
+}
+
+func (d *doctor) checkSigningKey(path string) {
+	data := d.checkKeyFile("signing key", path, "one is generated on startup")
+	if data == nil {
+		return
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		d.fail("signing key", "%s is not PEM encoded", path)
+		return
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if _, ok := key.(ed25519.PrivateKey); err != nil || !ok {
+		d.fail("signing key", "%s is not an ed25519 key", path)
+		return
+	}
+	d.ok("signing key", "%s", path)
+}
+
+// checkBackends sees whether each configured provider's service answers at
+// all. Any HTTP response counts; no question is asked.
+func (d *doctor) checkBackends() {
+	if setting("offline") == "true" {
+		d.ok("backend", "offline, telling fortunes")
+		return
+	}
+	urls := map[string]string{
+		"ponder": setting("endpoint"),
+		"ollama": strings.TrimRight(setting("ollama-url"), "/") + "/api/tags",
+		"openai": strings.TrimRight(setting("openai-url"), "/") + "/models",
+	}
+	for _, name := range parseList(setting("provider")) {
+		if url, ok := urls[name]; ok {
+			d.probe("backend "+name, url)
+		}
+	}
+	if compare := setting("compare"); compare != "" {
+		d.probe("backend compare", compare)
+	}
+}
+
+func (d *doctor) probe(check, url string) {
+	client := &http.Client{Timeout: doctorTimeout}
+	start := time.Now()
+	resp, err := client.Get(url)
+	if err != nil {
+		d.fail(check, "%s unreachable: %v", url, err)
+		return
+	}
+	resp.Body.Close()
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if resp.StatusCode >= 500 {
+		d.warn(check, "%s answered %s in %s", url, resp.Status, elapsed)
+		return
+	}
+	d.ok(check, "%s reachable in %s", url, elapsed)
+}
+
+// checkHistory reads the history and the schema versions it was written with.
+func (d *doctor) checkHistory(path string) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		d.ok("history", "%s (nothing recorded yet)", path)
+		return
+	}
+	if err != nil {
+		d.fail("history", "%v", err)
+		return
+	}
+	defer f.Close()
+
+	records, unreadable, newest := 0, 0, 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec historyRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			unreadable++
+			continue
+		}
+		records++
+		newest = max(newest, rec.Schema)
+	}
+	switch {
+	case scanner.Err() != nil:
+		d.fail("history", "%s: %v", path, scanner.Err())
+	case newest > historySchema:
+		d.fail("history", "%s has schema v%d records; this orb reads up to v%d", path, newest, historySchema)
+	case unreadable > 0:
+		d.warn("history", "%s: %d records, %d unreadable lines skipped", path, records, unreadable)
+	default:
+		d.ok("history", "%s: %d records, schema v%d", path, records, historySchema)
+	}
+}
+
+// checkTerminal describes the terminal orb doctor runs in.
+func (d *doctor) checkTerminal() {
+	term, colorterm := os.Getenv("TERM"), os.Getenv("COLORTERM")
+	profile := termenv.EnvColorProfile()
+	detail := fmt.Sprintf("TERM=%q COLORTERM=%q, colors: %s", term, colorterm, profile.Name())
+	info, err := os.Stdout.Stat()
+	switch {
+	case err != nil || info.Mode()&os.ModeCharDevice == 0:
+		d.warn("terminal", "%s; output isn't a terminal, run orb doctor where you ponder", detail)
+	case profile == termenv.Ascii:
+		d.warn("terminal", "%s; the orb will be shaded without color", detail)
+	default:
+		d.ok("terminal", "%s", detail)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// An easing curve maps how far an effect is through its time, from 0 to
+// 1, to how far along it should look
+type easing func(t float64) float64
+
+// Curves effects can be timed with
+var easings = map[string]easing{
+	"linear": func(t float64) float64 { return t },
+	// Slow to start and to settle, like the orb's breath
+	"smooth": func(t float64) float64 { return t * t * (3 - 2*t) },
+	// Overshoots a little and settles back, like a damped spring
+	"spring": func(t float64) float64 {
+		if t >= 1 {
+			return 1
+		}
+		return 1 - math.Exp(-6*t)*math.Cos(3*math.Pi*t)
+	},
+}
+
+// The curve the orb's effects are timed with, set by --easing
+var effectEasing = easings["smooth"]
+
+// parseEasing looks up a curve by name.
+func parseEasing(name string) (easing, error) {
+	if e, ok := easings[name]; ok {
+		return e, nil
+	}
+	names := slices.Sorted(maps.Keys(easings))
+	return nil, fmt.Errorf("unknown easing %q, want one of %s", name, strings.Join(names, ", "))
+}
+
+// ease runs progress through the effect curve, clamping it to 0 to 1 first.
+func ease(progress float64) float64 {
+	return effectEasing(min(max(progress, 0), 1))
+}
+
+// progress is how far through an effect of duration started at start now
+// is, from 0 to 1.
+func progress(start time.Time, duration time.Duration, now time.Time) float64 {
+	if duration <= 0 {
+		return 1
+	}
+	return min(max(float64(now.Sub(start))/float64(duration), 0), 1)
+}
+
+// lerp goes t of the way from a to b.
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
+
+// lerpHue goes t of the way from hue a to hue b, the shortest way around
+// the color wheel.
+func lerpHue(a, b, t float64) float64 {
+	diff := math.Mod(b-a+540, 360) - 180
+	return math.Mod(a+diff*t+360, 360)
+}
+
+// approach moves current toward target by rate of the distance left, and
+// by at least step, without passing it: quick while far behind, gentle as
+// it arrives.
+func approach(current, target, step int, rate float64) int {
+	if current >= target {
+		return target
+	}
+	move := max(step, int(float64(target-current)*rate))
+	return min(current+move, target)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// A message with the question composed in the editor
+type editorDoneMsg struct {
+	text string
+	err  error
+}
+
+// openEditorCmd suspends the TUI and lets the user compose a question in
+// $EDITOR (vi by default), starting from the current draft.
+func openEditorCmd(draft string) tea.Cmd {
+	f, err := os.CreateTemp("", "orb-question-*.txt")
+	if err != nil {
+		return func() tea.Msg { return editorDoneMsg{err: err} }
+	}
+	_, err = f.WriteString(draft)
+	f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return func() tea.Msg { return editorDoneMsg{err: err} }
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	c := exec.Command("sh", "-c", editor+` "$1"`, "sh", f.Name())
+	return tea.ExecProcess(c, func(err error) tea.Msg {
+		defer os.Remove(f.Name())
+		if err != nil {
+			return editorDoneMsg{err: err}
+		}
+		data, err := os.ReadFile(f.Name())
+		if err != nil {
+			return editorDoneMsg{err: err}
+		}
+		// The prompt is a single line, so paragraphs are joined up
+		return editorDoneMsg{text: strings.Join(strings.Fields(string(data)), " ")}
+	})
+}
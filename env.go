@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+)
+
+// Client environment variables accepted from SSH sessions by default.
+// Anything a client sends that isn't on the allowlist is dropped.
+var defaultAcceptedEnv = []string{"LANG", "TERM", "COLORTERM", "TZ", "ORB_THEME", "ORB_PERSONA", "ORB_WIDTH", "ORB_ALIGN", "ORB_SPACING", "ORB_COLORS", "NO_COLOR"}
+
+// The allowlist in effect, overridden by --accept-env
+var acceptedEnv = defaultAcceptedEnv
+
+// parseList splits a comma separated list, dropping empty entries.
+func parseList(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// sessionEnv filters KEY=VALUE pairs sent by a client down to the allowed names.
+func sessionEnv(environ []string, allowed []string) map[string]string {
+	env := make(map[string]string)
+	for _, kv := range environ {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		for _, name := range allowed {
+			if key == name {
+				env[key] = value
+				break
+			}
+		}
+	}
+	return env
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Extra facts a provider reports about an answer, alongside its text
+type answerMeta struct {
+	expires  time.Time // When the answer goes stale, zero if the provider didn't say
+	provider string    // Which provider of a fallback chain answered
+	notes    []string  // What was done to the question and answer along the way
+}
+
+type answerMetaKey struct{}
+
+// withAnswerMeta gives providers somewhere to report answer metadata.
+func withAnswerMeta(ctx context.Context, meta *answerMeta) context.Context {
+	return context.WithValue(ctx, answerMetaKey{}, meta)
+}
+
+// answerMetaFrom returns the metadata sink for this request, or a throwaway
+// one when the caller isn't interested.
+func answerMetaFrom(ctx context.Context) *answerMeta {
+	if meta, ok := ctx.Value(answerMetaKey{}).(*answerMeta); ok {
+		return meta
+	}
+	return &answerMeta{}
+}
+
+// A known full moon and the length of the lunar cycle
+var (
+	referenceFullMoon = time.Date(2000, time.January, 21, 4, 40, 0, 0, time.UTC)
+	synodicMonth      = time.Duration(29.530588853 * 24 * float64(time.Hour))
+)
+
+// nextFullMoon returns the first full moon after t. Answers that don't come
+// with an expiry are valid until then.
+func nextFullMoon(t time.Time) time.Time {
+	cycles := math.Floor(float64(t.Sub(referenceFullMoon)) / float64(synodicMonth))
+	return referenceFullMoon.Add(time.Duration((cycles + 1) * float64(synodicMonth)))
+}
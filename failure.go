@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// Kinds of failure providers' errors wrap, so each can be met with its own
+// words and counted apart
+var (
+	ErrRateLimited = errors.New("rate limited")
+	ErrTimeout     = errors.New("timed out")
+	ErrModerated   = errors.New("refused by moderation")
+	ErrBackendDown = errors.New("backend down")
+	ErrBadResponse = errors.New("bad response")
+)
+
+// Questions that failed, by kind of failure
+var metricAnswerErrors = expvar.NewMap("answer_errors")
+
+// A kind of failure as the asker and the metrics see it
+type failureKind struct {
+	err    error
+	metric string
+	words  string // What the orb says; empty for the flavor's silence
+}
+
+var failureKinds = []failureKind{
+	{ErrRateLimited, "rate_limited", "The orb has been asked too much of late. Rest a moment, then ask again."},
+	{ErrTimeout, "timeout", "The cosmos was slow to answer. Ask again in a moment."},
+	{ErrModerated, "moderated", "Some questions the orb will not ponder."},
+	{ErrBackendDown, "backend_down", "The cosmos is out of reach. The orb will listen again soon."},
+	{ErrBadResponse, "bad_response", ""},
+}
+
+// classifyFailure works out which kind of failure err is. Errors that
+// don't say are taken for bad responses.
+func classifyFailure(err error) failureKind {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		err = ErrTimeout
+	case unreachable(err) || errors.Is(err, errAllBreakersOpen):
+		err = ErrBackendDown
+	}
+	for _, kind := range failureKinds {
+		if errors.Is(err, kind.err) {
+			return kind
+		}
+	}
+	return failureKinds[len(failureKinds)-1]
+}
+
+// countFailure classifies err and counts it in the metrics.
+func countFailure(err error) failureKind {
+	kind := classifyFailure(err)
+	metricAnswerErrors.Add(kind.metric, 1)
+	return kind
+}
+
+// failureWords is what the orb says after failing to answer in persona.
+func (k failureKind) failureWords(persona string) string {
+	if k.words == "" {
+		return flavorFor(persona).silence
+	}
+	return k.words
+}
+
+// statusError describes a response from backend that wasn't a 200 as the
+// kind of failure its status means.
+func statusError(backend string, status int, detail string) error {
+	kind := ErrBadResponse
+	switch {
+	case status == http.StatusTooManyRequests:
+		kind = ErrRateLimited
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		kind = ErrTimeout
+	case status == http.StatusUnavailableForLegalReasons:
+		kind = ErrModerated
+	case status >= 500:
+		kind = ErrBackendDown
+	}
+	if detail != "" {
+		return fmt.Errorf("%s returned status %d: %s: %w", backend, status, detail, kind)
+	}
+	return fmt.Errorf("%s returned status %d: %w", backend, status, kind)
+}
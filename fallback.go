@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Circuit breaker settings, set by --breaker-failures and --breaker-window:
+// this many failures within the window take a provider out of the chain for
+// the length of the window.
+var (
+	breakerFailures = 3
+	breakerWindow   = 30 * time.Second
+)
+
+// circuitBreaker remembers a provider's recent failures.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  []time.Time
+	openUntil time.Time
+}
+
+// allow reports whether the provider may be asked.
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !now.Before(b.openUntil)
+}
+
+// failure records a failed question, returning true if it opened the breaker.
+func (b *circuitBreaker) failure(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	recent := b.failures[:0]
+	for _, t := range b.failures {
+		if now.Sub(t) < breakerWindow {
+			recent = append(recent, t)
+		}
+	}
+	b.failures = append(recent, now)
+	if len(b.failures) < breakerFailures {
+		return false
+	}
+	b.failures = nil
+	b.openUntil = now.Add(breakerWindow)
+	return true
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = nil
+}
+
+// A provider in a fallback chain
+type chainLink struct {
+	name     string
+	provider WisdomProvider
+	breaker  circuitBreaker
+}
+
+// fallbackProvider asks providers in order, moving on when one fails or its
+// circuit breaker is open.
+type fallbackProvider struct {
+	links []*chainLink
+}
+
+var errAllBreakersOpen = errors.New("every provider is resting after repeated failures")
+
+// newProviderChain builds the provider for a --provider value: one name, or
+// a comma separated list of fallbacks in order.
+func newProviderChain(list string) (WisdomProvider, error) {
+	names := parseList(list)
+	if len(names) == 1 {
+		return newProvider(names[0])
+	}
+	if len(names) == 0 {
+		return nil, errors.New("no provider given")
+	}
+	p := &fallbackProvider{}
+	for _, name := range names {
+		provider, err := newProvider(name)
+		if err != nil {
+			return nil, err
+		}
+		p.links = append(p.links, &chainLink{name: name, provider: provider})
+	}
+	return p, nil
+}
+
+// try asks each available provider in turn with ask, which reports whether
+// it's still safe to fall back after an error.
+func (p *fallbackProvider) try(ctx context.Context, ask func(WisdomProvider) (string, bool, error)) (string, error) {
+	err := errAllBreakersOpen
+	var passed []string
+	for _, link := range p.links {
+		if !link.breaker.allow(time.Now()) {
+			passed = append(passed, link.name+" (resting)")
+			continue
+		}
+		answer, canFallBack, askErr := ask(link.provider)
+		if askErr == nil {
+			link.breaker.success()
+			meta := answerMetaFrom(ctx)
+			meta.provider = link.name
+			if len(passed) > 0 {
+				meta.note("%s answered after %s", link.name, strings.Join(passed, ", "))
+			}
+			return answer, nil
+		}
+		passed = append(passed, link.name+" (failed)")
+		if ctx.Err() != nil {
+			return "", askErr
+		}
+		err = fmt.Errorf("%s: %w", link.name, askErr)
+		if link.breaker.failure(time.Now()) {
+			loggerFrom(ctx).Warn("provider keeps failing, skipping it for a while", "provider", link.name, "failures", breakerFailures, "window", breakerWindow)
+		}
+		if !canFallBack {
+			return "", err
+		}
+		loggerFrom(ctx).Error("provider failed, falling back", "provider", link.name, "request", requestIDFrom(ctx), "err", askErr)
+	}
+	return "", err
+}
+
+func (p *fallbackProvider) GetAnswer(ctx context.Context, question string) (string, error) {
+	return p.try(ctx, func(provider WisdomProvider) (string, bool, error) {
+		answer, err := provider.GetAnswer(ctx, question)
+		return answer, true, err
+	})
+}
+
+// StreamAnswer streams from whichever provider answers. Once part of an
+// answer has been shown, a failure can't be handed to the next provider.
+func (p *fallbackProvider) StreamAnswer(ctx context.Context, question string, onChunk func(string)) (string, error) {
+	return p.try(ctx, func(provider WisdomProvider) (string, bool, error) {
+		streaming, ok := provider.(StreamingProvider)
+		if !ok {
+			answer, err := provider.GetAnswer(ctx, question)
+			if err == nil {
+				onChunk(answer)
+			}
+			return answer, true, err
+		}
+		started := false
+		answer, err := streaming.StreamAnswer(ctx, question, func(chunk string) {
+			started = true
+			onChunk(chunk)
+		})
+		return answer, !started, err
+	})
+}
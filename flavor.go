@@ -0,0 +1,49 @@
+package main
+
+// The flavor text a deployment or persona speaks with
+type flavor struct {
+	prompt      string // Heading above the input box
+	placeholder string // Shown in the empty input box
+	thinking    string // Next to the spinner while waiting
+	silence     string // Shown when no answer could be had
+}
+
+// The deployment's flavor, overridable with the --*-text flags
+var deploymentFlavor = flavor{
+	prompt:   "What is the knowledge you seek?",
+	thinking: "consulting the cosmos...",
+	silence:  "The cosmos is silent. Your question remains unanswered.",
+}
+
+// Flavor that personas lay over the deployment's; empty fields fall through
+var personaFlavors = map[string]flavor{
+	"genie": {
+		prompt:   "Your wish is my command... well, your question.",
+		thinking: "the genie strokes his beard...",
+		silence:  "The lamp is cold. The genie will not speak.",
+	},
+	"seer": {
+		prompt:   "Speak, seeker. What troubles you?",
+		thinking: "the seer gazes into the mist...",
+		silence:  "The mist does not part today.",
+	},
+}
+
+// flavorFor returns the text a session with the given persona should use.
+func flavorFor(persona string) flavor {
+	f := deploymentFlavor
+	p := personaFlavors[persona]
+	if p.prompt != "" {
+		f.prompt = p.prompt
+	}
+	if p.placeholder != "" {
+		f.placeholder = p.placeholder
+	}
+	if p.thinking != "" {
+		f.thinking = p.thinking
+	}
+	if p.silence != "" {
+		f.silence = p.silence
+	}
+	return f
+}
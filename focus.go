@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Color of the countdown ring drawn on the orb's rim
+const focusRingColor = "#FFD27F"
+
+// Where focus session stats are kept, set by --focus-stats; "" keeps them
+// beside the history
+var focusStatsPath = ""
+
+func focusStatsFile() string {
+	if focusStatsPath != "" {
+		return focusStatsPath
+	}
+	return filepath.Join(filepath.Dir(historyPath), "focus_stats.json")
+}
+
+// A running /focus timer
+type focusSession struct {
+	started  time.Time
+	duration time.Duration
+}
+
+// parseFocus reads "/focus [duration]", defaulting to a classic 25 minutes.
+func parseFocus(input string) (focusSession, error) {
+	duration := 25 * time.Minute
+	if arg := strings.TrimSpace(strings.TrimPrefix(input, "/focus")); arg != "" {
+		d, err := time.ParseDuration(arg)
+		if err != nil || d <= 0 {
+			return focusSession{}, fmt.Errorf("unknown focus duration %q", arg)
+		}
+		duration = d
+	}
+	return focusSession{started: time.Now(), duration: duration}, nil
+}
+
+// remaining is the fraction of the session still to go, from 1 down to 0.
+func (f focusSession) remaining(now time.Time) float64 {
+	left := f.duration - now.Sub(f.started)
+	if left <= 0 {
+		return 0
+	}
+	return float64(left) / float64(f.duration)
+}
+
+func (f focusSession) done(now time.Time) bool {
+	return now.Sub(f.started) >= f.duration
+}
+
+func (f focusSession) status(now time.Time) string {
+	left := (f.duration - now.Sub(f.started)).Round(time.Second)
+	return fmt.Sprintf("focus — %s remaining [esc to stop]", left)
+}
+
+// The question asked on the user's behalf once a session completes
+func (f focusSession) prophecyQuestion() string {
+	return fmt.Sprintf("I just finished %s of focused work. Offer me an encouraging prophecy.", f.duration.Round(time.Minute))
+}
+
+// Completed focus sessions for one identity
+type focusStats struct {
+	Sessions     int `json:"sessions"`
+	TotalMinutes int `json:"total_minutes"`
+}
+
+var focusStatsMu sync.Mutex
+
+// recordFocus adds a completed session to identity's stats on disk.
+func recordFocus(identity string, d time.Duration) (focusStats, error) {
+	focusStatsMu.Lock()
+	defer focusStatsMu.Unlock()
+
+	path := focusStatsFile()
+	all := make(map[string]focusStats)
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return focusStats{}, fmt.Errorf("failed to read focus stats: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &all); err != nil {
+			return focusStats{}, fmt.Errorf("failed to parse focus stats: %w", err)
+		}
+	}
+
+	stats := all[identity]
+	stats.Sessions++
+	stats.TotalMinutes += int(d.Minutes())
+	all[identity] = stats
+
+	data, err = json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return stats, fmt.Errorf("failed to encode focus stats: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return stats, fmt.Errorf("failed to create focus stats directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return stats, fmt.Errorf("failed to write focus stats: %w", err)
+	}
+	return stats, nil
+}
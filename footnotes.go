@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Longest answer shown, in runes; anything past it is cut
+const maxAnswerRunes = 8000
+
+// note records something done to the question or answer on the way, for
+// the answer's footnotes.
+func (m *answerMeta) note(format string, args ...any) {
+	m.notes = append(m.notes, fmt.Sprintf(format, args...))
+}
+
+// stripControl removes terminal escape sequences and control characters
+// other than newlines and tabs from provider text, so an answer can't drive
+// the terminal. It reports whether anything was removed.
+func stripControl(text string) (string, bool) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	cleaned := escapeSequence.ReplaceAllString(text, "")
+	cleaned = strings.Map(func(r rune) rune {
+		joiner := r == '\u200c' || r == '\u200d' // Shape Arabic and emoji
+		if r == '\n' || r == '\t' || joiner {
+			return r
+		}
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, cleaned)
+	return cleaned, cleaned != text
+}
+
+// cleanAnswer makes a provider's answer safe and reasonably sized to show,
+// noting what it changed.
+func cleanAnswer(answer string, meta *answerMeta) string {
+	answer, stripped := stripControl(answer)
+	if stripped {
+		meta.note("terminal control characters were removed from the answer")
+	}
+	if utf8.RuneCountInString(answer) > maxAnswerRunes {
+		answer = string([]rune(answer)[:maxAnswerRunes]) + "…"
+		meta.note("the answer was cut to %d characters", maxAnswerRunes)
+	}
+	return answer
+}
+
+// noteQuestion records how the question was changed before it was sent.
+func noteQuestion(question string, meta *answerMeta) {
+	switch {
+	case utf8.RuneCountInString(question) > maxQuestionRunes:
+		meta.note("the question was cut to %d characters before it was sent", maxQuestionRunes)
+	case sanitizeQuestion(question) != strings.Join(strings.Fields(question), " "):
+		meta.note("control characters, repeats or question tags were removed from the question")
+	}
+}
+
+// footnotes lists what was applied to the current answer, opened with f.
+func footnotes(notes []string) string {
+	var b strings.Builder
+	b.WriteString("what happened along the way\n")
+	for _, n := range notes {
+		b.WriteString("\n• " + n)
+	}
+	b.WriteString("\n\nclose [f]")
+	return b.String()
+}
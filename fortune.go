@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+//go:embed fortunes.txt
+var bundledFortunes string
+
+// Fortunes told when the wisdom provider can't be reached, extended by
+// --fortunes
+var fortunes = parseFortunes(bundledFortunes)
+
+// Fallback fortunes go stale quickly so the question can be re-asked once
+// the cosmos is back
+const fortuneTTL = time.Minute
+
+// parseFortunes reads one fortune per line, skipping blanks and # comments.
+func parseFortunes(text string) []string {
+	var list []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			list = append(list, line)
+		}
+	}
+	return list
+}
+
+// loadFortunes adds the fortunes in a user-supplied file.
+func loadFortunes(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read fortunes: %w", err)
+	}
+	fortunes = append(fortunes, parseFortunes(string(data))...)
+	return nil
+}
+
+// unreachable reports whether err means the provider couldn't be reached at
+// all, as opposed to answering badly.
+func unreachable(err error) bool {
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	return errors.As(err, &opErr) || errors.As(err, &dnsErr) ||
+		(errors.As(err, &netErr) && netErr.Timeout())
+}
+
+func randomFortune() string {
+	return fortunes[rand.Intn(len(fortunes))]
+}
+
+// fortuneProvider answers from the fortune database alone, for --offline.
+type fortuneProvider struct{}
+
+func (fortuneProvider) GetAnswer(ctx context.Context, question string) (string, error) {
+	return randomFortune(), nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	handoffCommand = "/handoff"
+	handoffTTL     = 5 * time.Minute // How long a handoff code can be claimed
+	handoffLen     = 10              // 50 bits, too many to guess within handoffTTL
+)
+
+// Handoff claims each address may try a minute, after a burst of a few, so
+// codes can't be guessed by trying them all
+var handoffLimiter = newIPLimiter(3, 5)
+
+// Letters and digits that can't be mistaken for one another when read off
+// one screen and typed on another
+const handoffAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// What moves with a session handed off to another device
+type handoffState struct {
+	conversation []exchange
+	recall       questionRecall
+	draft        string
+	notes        string
+	attachNotes  bool
+	theme        theme
+	themeName    string
+	persona      string
+}
+
+// A session waiting to be handed off
+type handoffOffer struct {
+	claims   chan chan handoffState
+	identity string // Key fingerprint the claim must come from, "" for any
+}
+
+// Sessions waiting to be handed off, keyed by code. Claiming a code sends
+// the waiting session a channel to reply on with its state.
+type handoffStore struct {
+	mu      sync.Mutex
+	waiting map[string]handoffOffer
+}
+
+var handoffs = &handoffStore{waiting: make(map[string]handoffOffer)}
+
+// open registers a new one-time code. When identity isn't empty only a
+// session with the same key can claim it.
+func (h *handoffStore) open(identity string) (string, chan chan handoffState) {
+	code := make([]byte, handoffLen)
+	rand.Read(code)
+	for i, b := range code {
+		code[i] = handoffAlphabet[int(b)%len(handoffAlphabet)]
+	}
+	claims := make(chan chan handoffState)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.waiting[string(code)] = handoffOffer{claims: claims, identity: identity}
+	return string(code), claims
+}
+
+func (h *handoffStore) close(code string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.waiting, code)
+}
+
+// claim takes the state of the session waiting on code for identity, which
+// then closes. The code can't be used again.
+func (h *handoffStore) claim(code, identity string) (handoffState, error) {
+	h.mu.Lock()
+	offer, ok := h.waiting[code]
+	if ok && offer.identity != "" && offer.identity != identity {
+		ok = false // Left waiting for the key it was offered to
+	}
+	if ok {
+		delete(h.waiting, code)
+	}
+	h.mu.Unlock()
+	if !ok {
+		return handoffState{}, errors.New("no session is waiting on that code")
+	}
+	reply := make(chan handoffState, 1)
+	select {
+	case offer.claims <- reply:
+	case <-time.After(5 * time.Second):
+		return handoffState{}, errors.New("the other session let go")
+	}
+	select {
+	case state := <-reply:
+		return state, nil
+	case <-time.After(5 * time.Second):
+		return handoffState{}, errors.New("the other session did not answer")
+	}
+}
+
+// A session claimed this one's handoff code
+type handoffClaimedMsg struct{ reply chan handoffState }
+
+// This session's handoff code went unclaimed
+type handoffExpiredMsg struct{ code string }
+
+// The state of a session claimed with /handoff CODE
+type handoffMsg struct {
+	state handoffState
+	err   error
+}
+
+// awaitHandoffCmd waits for the session's code to be claimed, giving it up
+// when it expires or the session ends.
+func (m model) awaitHandoffCmd(code string, claims chan chan handoffState) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case reply := <-claims:
+			return handoffClaimedMsg{reply: reply}
+		case <-m.session.Done():
+			handoffs.close(code)
+			return nil
+		case <-time.After(handoffTTL):
+			handoffs.close(code)
+			return handoffExpiredMsg{code: code}
+		}
+	}
+}
+
+func claimHandoffCmd(code, identity string) tea.Cmd {
+	return func() tea.Msg {
+		state, err := handoffs.claim(code, identity)
+		return handoffMsg{state: state, err: err}
+	}
+}
+
+// isHandoff reports whether input is the /handoff command, with or without
+// a code.
+func isHandoff(input string) bool {
+	return input == handoffCommand || strings.HasPrefix(input, handoffCommand+" ")
+}
+
+// handoff handles "/handoff", which offers this session to another device,
+// and "/handoff CODE", which takes over the session offering it. Sessions
+// with a key can only be taken over by sessions with the same key.
+func (m model) handoff(input string) (tea.Model, tea.Cmd) {
+	m.textInput.Reset()
+	if m.session == nil {
+		// Another session can only find this one inside the same server
+		m.textInput.Placeholder = "handoff is for sessions on the orb's server"
+		return m, nil
+	}
+	code := strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(input, handoffCommand)))
+	if code != "" {
+		if code == m.handoffCode {
+			m.textInput.Placeholder = "enter that code in your other session"
+			return m, nil
+		}
+		if wait := handoffLimiter.take(m.addr, time.Now()); wait > 0 {
+			metricIPLimited.Add("handoffs", 1)
+			m.textInput.Placeholder = fmt.Sprintf("the orb needs a moment; try again in %v", max(wait.Round(time.Second), time.Second))
+			return m, nil
+		}
+		m.textInput.Placeholder = "the orb reaches for your other session…"
+		return m, claimHandoffCmd(code, m.identity)
+	}
+	handoffs.close(m.handoffCode)
+	code, claims := handoffs.open(m.identity)
+	m.handoffCode = code
+	return m, m.awaitHandoffCmd(code, claims)
+}
+
+// handoffState is what the session claiming this one takes over.
+func (m model) handoffState() handoffState {
+	return handoffState{
+		conversation: m.conversation,
+		recall:       m.recall,
+		draft:        m.textInput.Value(),
+		notes:        m.notes.Value(),
+		attachNotes:  m.attachNotes,
+		theme:        m.theme,
+		themeName:    m.themeName,
+		persona:      m.persona,
+	}
+}
+
+// takeOver continues a handed off session in this one.
+func (m *model) takeOver(s handoffState) {
+	m.conversation = s.conversation
+	m.recall = s.recall
+	m.notes.SetValue(s.notes)
+	m.attachNotes = s.attachNotes
+	m.setTheme(s.theme)
+	m.themeName = s.themeName
+	m.persona = s.persona
+	m.textInput.Placeholder = "the orb followed you here"
+	m.textInput.SetValue(s.draft)
+	m.textInput.CursorEnd()
+}
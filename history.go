@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Where questions and answers are recorded, set by --history
+var historyPath = defaultHistoryPath()
+
+// Version of the history record format, written with each record so a
+// future format can tell old records apart. Records without one are v1.
+const historySchema = 1
+
+// Size in bytes the history may grow to before it's rotated into an
+// archive, set by --history-max-size. 0 lets it grow.
+var historyMaxSize int64 = 100 << 20
+
+// Serializes writes to the history with archiving and restoring
+var historyMu sync.Mutex
+
+// defaultHistoryPath returns $XDG_DATA_HOME/orb/history.jsonl, falling back
+// to ~/.local/share when XDG_DATA_HOME isn't set.
+func defaultHistoryPath() string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "history.jsonl"
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "orb", "history.jsonl")
+}
+
+// One question put to the orb, stored as a line of JSON
+type historyRecord struct {
+	Schema    int       `json:"v,omitempty"`
+	Time      time.Time `json:"time"`
+	Identity  string    `json:"identity,omitempty"` // Who asked, as in model.identity
+	RequestID string    `json:"request_id,omitempty"`
+	Question  string    `json:"question"`
+	Answer    string    `json:"answer"`
+	Failed    bool      `json:"failed,omitempty"` // Answer is the error text shown instead
+	Fresh     bool      `json:"fresh,omitempty"`  // Asked with no conversation before it
+	Seal      string    `json:"seal,omitempty"`
+	Signature string    `json:"signature,omitempty"`
+	Language  string    `json:"language,omitempty"`
+	Runes     int       `json:"runes,omitempty"`
+	ReadingMs int64     `json:"reading_ms,omitempty"`
+}
+
+// recordHistory appends a question and its answer to the history.
+func recordHistory(rec historyRecord) {
+	rec.Schema = historySchema
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(historyPath), 0755); err != nil {
+		slog.Error("recording history", "err", err)
+		return
+	}
+	if path, err := rotateHistory(time.Now()); err != nil {
+		slog.Error("rotating history", "err", err)
+	} else if path != "" {
+		slog.Info("rotated history", "path", path)
+	}
+	f, err := os.OpenFile(historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		slog.Error("recording history", "err", err)
+		return
+	}
+	defer f.Close()
+	if err := json.NewEncoder(f).Encode(rec); err != nil {
+		slog.Error("recording history", "err", err)
+	}
+}
+
+// decodeHistory reads JSONL records, skipping lines that don't parse.
+func decodeHistory(r io.Reader) ([]historyRecord, error) {
+	var records []historyRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec historyRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err == nil {
+			records = append(records, rec)
+		}
+	}
+	return records, scanner.Err()
+}
+
+func readHistoryFile() ([]historyRecord, error) {
+	f, err := os.Open(historyPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+	records, err := decodeHistory(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return records, nil
+}
+
+// loadHistory returns the latest limit records asked by identity, newest first.
+func loadHistory(identity string, limit int) ([]historyRecord, error) {
+	historyMu.Lock()
+	records, err := readHistoryFile()
+	historyMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var mine []historyRecord
+	for i := len(records) - 1; i >= 0 && len(mine) < limit; i-- {
+		if records[i].Identity == identity {
+			mine = append(mine, records[i])
+		}
+	}
+	return mine, nil
+}
+
+// deleteHistory removes identity's records that match from the history and
+// returns them.
+func deleteHistory(identity string, match func(historyRecord) bool) ([]historyRecord, error) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	records, err := readHistoryFile()
+	if err != nil {
+		return nil, err
+	}
+	var removed, keep []historyRecord
+	for _, rec := range records {
+		if rec.Identity == identity && match(rec) {
+			removed = append(removed, rec)
+		} else {
+			keep = append(keep, rec)
+		}
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	if err := writeHistoryFile(keep); err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// sameRecord reports whether a and b are the same question asked once.
+func sameRecord(a, b historyRecord) bool {
+	return a.Time.Equal(b.Time) && a.RequestID == b.RequestID && a.Question == b.Question
+}
+
+// writeHistoryFile atomically replaces the history.
+func writeHistoryFile(records []historyRecord) error {
+	tmp := historyPath + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	enc := json.NewEncoder(f)
+	for _, rec := range records {
+		if err = enc.Encode(rec); err != nil {
+			break
+		}
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := os.Rename(tmp, historyPath); err != nil {
+		return fmt.Errorf("failed to replace history: %w", err)
+	}
+	return nil
+}
+
+// archiveHistory moves records older than age out of the history into a
+// gzipped JSONL file next to it, returning the archive's path ("" when
+// nothing was old enough).
+func archiveHistory(now time.Time, age time.Duration) (string, error) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	records, err := readHistoryFile()
+	if err != nil {
+		return "", err
+	}
+	var old, keep []historyRecord
+	for _, rec := range records {
+		if rec.Time.Before(now.Add(-age)) {
+			old = append(old, rec)
+		} else {
+			keep = append(keep, rec)
+		}
+	}
+	if len(old) == 0 {
+		return "", nil
+	}
+
+	path := archivePath(now)
+	if err := writeArchive(path, old); err != nil {
+		return "", err
+	}
+	if err := writeHistoryFile(keep); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// rotateHistory moves the whole history into an archive once it has grown
+// past historyMaxSize, returning the archive's path ("" when it hasn't).
+// The caller holds historyMu.
+func rotateHistory(now time.Time) (string, error) {
+	info, err := os.Stat(historyPath)
+	if historyMaxSize <= 0 || errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to stat history: %w", err)
+	}
+	if info.Size() < historyMaxSize {
+		return "", nil
+	}
+
+	records, err := readHistoryFile()
+	if err != nil {
+		return "", err
+	}
+	path := archivePath(now)
+	if err := writeArchive(path, records); err != nil {
+		return "", err
+	}
+	if err := writeHistoryFile(nil); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// archivePath names an archive of the history made at now.
+func archivePath(now time.Time) string {
+	return strings.TrimSuffix(historyPath, ".jsonl") + "-" + now.Format("20060102-150405") + ".jsonl.gz"
+}
+
+func writeArchive(path string, records []historyRecord) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	zw := gzip.NewWriter(f)
+	enc := json.NewEncoder(zw)
+	for _, rec := range records {
+		if err = enc.Encode(rec); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+func readArchive(path string) ([]historyRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	records, err := decodeHistory(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	return records, nil
+}
+
+// restoreHistory merges an archive back into the history in time order.
+func restoreHistory(archive string) (int, error) {
+	restored, err := readArchive(archive)
+	if err != nil {
+		return 0, err
+	}
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	records, err := readHistoryFile()
+	if err != nil {
+		return 0, err
+	}
+	records = append(restored, records...)
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	if err := writeHistoryFile(records); err != nil {
+		return 0, err
+	}
+	return len(restored), nil
+}
+
+// archiveOldHistory archives history older than age, if archiving is on.
+func archiveOldHistory(age time.Duration) {
+	if age <= 0 {
+		return
+	}
+	path, err := archiveHistory(time.Now(), age)
+	if err != nil {
+		slog.Error("archiving history", "err", err)
+	} else if path != "" {
+		slog.Info("archived old history", "path", path)
+	}
+}
+
+// runArchiver keeps a long-running server's history trim, once a day.
+func runArchiver(age time.Duration) {
+	if age <= 0 {
+		return
+	}
+	for range time.Tick(24 * time.Hour) {
+		archiveOldHistory(age)
+	}
+}
+
+// runRestore puts archived history back into the history:
+//
+//	orb restore ~/.local/share/orb/history-20260101-000000.jsonl.gz
+func runRestore(args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	flags.StringVar(&historyPath, "history", historyPath, "history to restore into")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: orb restore [flags] archive.jsonl.gz...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	for _, archive := range flags.Args() {
+		n, err := restoreHistory(archive)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("restored %d records from %s into %s\n", n, filepath.Base(archive), historyPath)
+	}
+}
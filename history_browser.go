@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Most past questions the history browser loads
+const historyBrowseLimit = 200
+
+// Past questions listed at once in the history browser
+const historyPageSize = 8
+
+// Browsing earlier questions and answers, opened with ctrl+o
+type historyBrowser struct {
+	records  []historyRecord // Newest first
+	cursor   int
+	clearing bool // Asking whether to delete every record
+}
+
+// historyRecord describes the current exchange for the history.
+func (m model) historyRecord() historyRecord {
+	return historyRecord{
+		Time:      time.Now().UTC(),
+		Identity:  m.identity,
+		RequestID: m.requestID,
+		Question:  m.question,
+		Answer:    m.answer,
+		Seal:      m.seal,
+		Signature: m.signature,
+		Language:  m.stats.language,
+		Runes:     m.stats.runes,
+		ReadingMs: m.stats.reading.Milliseconds(),
+	}
+}
+
+// openHistory loads the asker's history into the browser. Clients without
+// a verified identity can't be told apart, so they get none.
+func (m model) openHistory() (tea.Model, tea.Cmd) {
+	if m.identity == "" {
+		m.textInput.Placeholder = "the orb only remembers those who bring an ssh key"
+		return m, nil
+	}
+	records, err := loadHistory(m.identity, historyBrowseLimit)
+	if err != nil {
+		m.logger.Error("loading history", "err", err)
+		m.textInput.Placeholder = "the orb's memory is clouded"
+		return m, nil
+	}
+	if len(records) == 0 {
+		m.textInput.Placeholder = "nothing asked yet"
+		return m, nil
+	}
+	m.history = &historyBrowser{records: records}
+	m.textInput.Blur()
+	return m, nil
+}
+
+func (m model) browseHistory(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	h := m.history
+	if h.clearing {
+		h.clearing = false
+		if msg.String() == "y" {
+			return m.deleteHistory("clear", func(historyRecord) bool { return true })
+		}
+		return m, nil
+	}
+	switch msg.String() {
+	case "ctrl+c":
+		return m.quit()
+	case "up", "k":
+		h.cursor = max(h.cursor-1, 0)
+	case "down", "j":
+		h.cursor = min(h.cursor+1, len(h.records)-1)
+	case "esc", "ctrl+o":
+		return m.closeHistory()
+	case "x", "delete":
+		chosen := h.records[h.cursor]
+		return m.deleteHistory("delete", func(rec historyRecord) bool { return sameRecord(rec, chosen) })
+	case "X":
+		h.clearing = true
+	case "enter":
+		// Bring the chosen answer back as if it had just been given
+		rec := h.records[h.cursor]
+		m.history = nil
+		m.question, m.answer = rec.Question, rec.Answer
+		m.seal, m.signature = rec.Seal, rec.Signature
+		m.stats = measureAnswer(rec.Answer)
+		m.requestID = rec.RequestID
+		m.notice = "From " + rec.Time.Local().Format("Jan 2, 15:04")
+		m.answeredBy, m.previous, m.expires = "", "", time.Time{}
+		m.streamed, m.typing = "", false
+		m.answerPort.GotoTop()
+		m.footnotes, m.showingNotes = nil, false
+		m.showingAnswer = true
+		return m, nil
+	}
+	return m, nil
+}
+
+// deleteHistory removes the matching records from the history and the
+// browser, logging the deletion for the audit trail. The orb forgets them
+// too, so they aren't recalled or sent along with the next question.
+func (m model) deleteHistory(action string, match func(historyRecord) bool) (tea.Model, tea.Cmd) {
+	removed, err := deleteHistory(m.identity, match)
+	if err != nil {
+		m.logger.Error("deleting history", "err", err)
+		m.textInput.Placeholder = "the orb's memory is clouded"
+		return m.closeHistory()
+	}
+	audit := auditRecord{Time: time.Now().UTC(), Identity: m.identity, Action: action, Count: len(removed)}
+	for _, rec := range removed {
+		if rec.RequestID != "" {
+			audit.RequestIDs = append(audit.RequestIDs, rec.RequestID)
+		}
+		m.recall.forget(rec.Question)
+		m.conversation = slices.DeleteFunc(m.conversation, func(ex exchange) bool {
+			return ex.Question == rec.Question && ex.Answer == rec.Answer
+		})
+	}
+	if err := recordAudit(audit); err != nil {
+		m.logger.Error("recording audit", "err", err)
+	}
+
+	h := m.history
+	h.records = slices.DeleteFunc(h.records, match)
+	if len(h.records) == 0 {
+		m.textInput.Placeholder = "the orb has forgotten everything you asked"
+		return m.closeHistory()
+	}
+	h.cursor = min(h.cursor, len(h.records)-1)
+	return m, nil
+}
+
+func (m model) closeHistory() (tea.Model, tea.Cmd) {
+	m.history = nil
+	m.textInput.Focus()
+	return m, textinput.Blink
+}
+
+// status renders the page of questions around the cursor and the answer
+// to the selected one.
+func (h historyBrowser) status(width int, layout textLayout, newStyle func() lipgloss.Style) string {
+	start := max(0, min(h.cursor-historyPageSize/2, len(h.records)-historyPageSize))
+	end := min(start+historyPageSize, len(h.records))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "history (%d)\n\n", len(h.records))
+	for i := start; i < end; i++ {
+		rec := h.records[i]
+		cursor := "  "
+		if i == h.cursor {
+			cursor = "▸ "
+		}
+		line := cursor + rec.Time.Local().Format("Jan 02 15:04") + "  " + rec.Question
+		if rec.Failed {
+			line += " (unanswered)"
+		}
+		b.WriteString(visualLine(truncateRunes(line, width), false) + "\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(layout.render(h.records[h.cursor].Answer, width, newStyle))
+	if h.clearing {
+		fmt.Fprintf(&b, "\n\ndelete all %d? [y/n]", len(h.records))
+	} else {
+		b.WriteString("\n\n↑/↓ choose · enter revisit · x delete · X delete all · esc close")
+	}
+	return b.String()
+}
+
+// truncateRunes shortens s to n runes, ending in an ellipsis when cut.
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n || n < 1 {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
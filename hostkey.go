@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// withHostKey serves the ssh host key at path, generating an ed25519 key
+// there on first run so deploying doesn't need ssh-keygen. Its fingerprint
+// is logged so clients can check what they're connecting to.
+func withHostKey(path string) (ssh.Option, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		data, err = generateHostKey(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0077 != 0 {
+		// sshd refuses keys like this; tighten it rather than refuse to start
+		slog.Warn("ssh host key was readable by others, making it private", "path", path, "mode", info.Mode().Perm())
+		if err := os.Chmod(path, 0600); err != nil {
+			return nil, fmt.Errorf("failed to protect host key: %w", err)
+		}
+	}
+	signer, err := gossh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse host key %s: %w", path, err)
+	}
+	slog.Info("ssh host key", "path", path, "type", signer.PublicKey().Type(), "fingerprint", gossh.FingerprintSHA256(signer.PublicKey()))
+	return func(s *ssh.Server) error {
+		s.AddHostKey(signer)
+		return nil
+	}, nil
+}
+
+// generateHostKey writes a new ed25519 host key in OpenSSH format, with its
+// public half beside it, and returns the private key file's contents.
+func generateHostKey(path string) ([]byte, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate host key: %w", err)
+	}
+	block, err := gossh.MarshalPrivateKey(priv, "orb host key")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode host key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create host key directory: %w", err)
+	}
+	data := pem.EncodeToMemory(block)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write host key: %w", err)
+	}
+
+	sshPub, err := gossh.NewPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key: %w", err)
+	}
+	if err := os.WriteFile(path+".pub", gossh.MarshalAuthorizedKey(sshPub), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write public key: %w", err)
+	}
+	slog.Info("generated a new ssh host key", "path", path)
+	return data, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Names for known clients by public key fingerprint, set by --aliases
+var keyAliases = map[string]string{}
+
+// How recently a returning client must have asked for their conversation
+// to be picked up again
+const conversationResumeAge = 24 * time.Hour
+
+// loadAliases reads public keys in authorized_keys format, each named by
+// its comment:
+//
+//	ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAA... ada
+func loadAliases(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open aliases: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, comment, _, _, err := gossh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return fmt.Errorf("failed to parse alias: %w", err)
+		}
+		if comment != "" {
+			keyAliases[gossh.FingerprintSHA256(key)] = comment
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read aliases: %w", err)
+	}
+	return nil
+}
+
+// displayName is what the orb calls a client: their alias, or else the
+// start of their key fingerprint.
+func displayName(identity string) string {
+	if alias, ok := keyAliases[identity]; ok {
+		return alias
+	}
+	return truncateRunes(identity, 16)
+}
+
+// resumeConversation returns the conversation a client was having when they
+// last left, if they asked recently, and whether they've been here before.
+func resumeConversation(identity string, now time.Time) ([]exchange, bool, error) {
+	records, err := loadHistory(identity, maxConversationTurns)
+	if err != nil || len(records) == 0 {
+		return nil, false, err
+	}
+	if conversationTurns <= 0 || now.Sub(records[0].Time) > conversationResumeAge {
+		return nil, true, nil
+	}
+	var conversation []exchange
+	for _, rec := range records {
+		if len(conversation) == conversationTurns {
+			break
+		}
+		if !rec.Failed {
+			conversation = append([]exchange{{Question: rec.Question, Answer: rec.Answer}}, conversation...)
+		}
+		if rec.Fresh {
+			break // Where the conversation started
+		}
+	}
+	return conversation, true, nil
+}
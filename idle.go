@@ -0,0 +1,41 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"time"
+)
+
+// Sessions disconnected for going idle
+var metricIdleDisconnects = expvar.NewInt("idle_disconnects")
+
+// How long an ssh or web session may go without a key press before it's
+// disconnected, set by --idle-timeout. 0 keeps idle sessions open.
+var idleTimeout = 30 * time.Minute
+
+// How long before disconnecting an idle session the orb warns about it
+const idleWarning = time.Minute
+
+// busy reports whether the orb is at work on the client's behalf, so a
+// quiet keyboard doesn't mean they've gone.
+func (m model) busy() bool {
+	return m.thinking || m.focus != nil || m.meditation != nil
+}
+
+// idleLeft is how long the session has until it's disconnected for being
+// idle, or false when it never will be.
+func (m model) idleLeft(now time.Time) (time.Duration, bool) {
+	if m.session == nil || idleTimeout <= 0 {
+		return 0, false
+	}
+	return idleTimeout - now.Sub(m.lastActive), true
+}
+
+// idleWarningText counts down the last minute of an idle session.
+func (m model) idleWarningText(now time.Time) string {
+	left, ok := m.idleLeft(now)
+	if !ok || left > idleWarning {
+		return ""
+	}
+	return fmt.Sprintf("The orb grows drowsy; it will close in %v unless you press a key.", max(left.Round(time.Second), 0))
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// Connections and questions turned away by the per-IP limits
+var metricIPLimited = expvar.NewMap("ip_limited")
+
+// A bucket of tokens refilled at a steady rate up to its burst
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// ipLimiter gives each source IP its own token bucket. A nil limiter lets
+// everything through.
+type ipLimiter struct {
+	rate  float64 // Tokens per second
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	swept   time.Time
+}
+
+// Per-IP limits on new ssh connections and on questions, set by
+// --conn-rate, --conn-burst, --question-rate and --question-burst
+var connLimiter, questionLimiter *ipLimiter
+
+// newIPLimiter allows perMinute a minute from each IP after an initial
+// burst, or returns nil when perMinute is 0.
+func newIPLimiter(perMinute float64, burst int) *ipLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &ipLimiter{rate: perMinute / 60, burst: float64(max(burst, 1)), buckets: make(map[string]*tokenBucket)}
+}
+
+// take spends a token for ip and returns 0, or how long until one is
+// available when the bucket is empty.
+func (l *ipLimiter) take(ip string, now time.Time) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[ip] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// sweep drops buckets that have filled up again, at most once a minute, so
+// the limiter doesn't grow with every address ever seen.
+func (l *ipLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < time.Minute {
+		return
+	}
+	l.swept = now
+	for ip, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, ip)
+		}
+	}
+}
+
+// remoteIP returns the IP address a session comes from.
+func remoteIP(s ssh.Session) string {
+	if host, _, err := net.SplitHostPort(s.RemoteAddr().String()); err == nil {
+		return host
+	}
+	return s.RemoteAddr().String()
+}
+
+// limitPerIP turns away sessions from an address opening them faster than
+// --conn-rate allows.
+func limitPerIP() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			if wait := connectionWait(remoteIP(s)); wait > 0 {
+				sessionLogger(s).Warn("too many connections")
+				wish.Fatalln(s, tryAgainIn(wait))
+				return
+			}
+			next(s)
+		}
+	}
+}
+
+// connectionWait spends a connection from ip and returns how long it must
+// wait first, or 0 when it may connect now.
+func connectionWait(ip string) time.Duration {
+	wait := connLimiter.take(ip, time.Now())
+	if wait > 0 {
+		metricIPLimited.Add("connections", 1)
+	}
+	return wait
+}
+
+// tryAgainIn tells a client turned away by a limit when to come back.
+func tryAgainIn(wait time.Duration) string {
+	return fmt.Sprintf("The orb needs a moment; try again in %v.", max(wait.Round(time.Second), time.Second))
+}
+
+// questionWait spends a question from ip and returns how long it must wait
+// first, or 0 when it may be asked now.
+func questionWait(ip string) time.Duration {
+	if ip == "" {
+		return 0
+	}
+	wait := questionLimiter.take(ip, time.Now())
+	if wait > 0 {
+		metricIPLimited.Add("questions", 1)
+	}
+	return wait
+}
@@ -0,0 +1,74 @@
+package main
+
+import "github.com/charmbracelet/lipgloss"
+
+// Terminal width from which side orbs are drawn when enabled
+const multiOrbMinWidth = 160
+
+// Whether wide terminals get decorative side orbs, set by --multi-orb
+var multiOrb = false
+
+// Geometry of everything View draws
+type layout struct {
+	width          int // Total width of the scene
+	orbWidth       int
+	radius         int
+	orbHeight      int
+	visibleHeight  int // Rows of the orb actually drawn
+	satelliteWidth int // Width of each side orb, 0 when there are none
+}
+
+// fitBox renders text in style, wrapping it when it would be wider than
+// width cells. Double-width runes count twice, as the terminal draws them.
+func fitBox(style lipgloss.Style, text string, width int) string {
+	if rendered := style.Render(text); lipgloss.Width(rendered) <= width {
+		return rendered
+	}
+	return style.Width(width).Align(lipgloss.Center).Render(text)
+}
+
+// computeLayout decides how big the main orb is and whether there's room
+// for side orbs next to it.
+func computeLayout(termWidth int, compact, multi bool) layout {
+	if termWidth <= 0 {
+		termWidth = 60
+	}
+
+	orbWidth := termWidth
+	if orbWidth > 100 {
+		orbWidth = 98
+	}
+	if compact && orbWidth > 60 {
+		orbWidth = 60
+	}
+
+	l := layout{width: orbWidth, orbWidth: orbWidth}
+	l.radius = orbWidth / 4
+	l.orbHeight = l.radius * 2
+	l.visibleHeight = int(float64(l.orbHeight) * 0.6)
+
+	if multi && !compact && termWidth >= multiOrbMinWidth {
+		l.satelliteWidth = (termWidth - orbWidth) / 2
+		if l.satelliteWidth > orbWidth/2 {
+			l.satelliteWidth = orbWidth / 2
+		}
+		l.width = orbWidth + 2*l.satelliteWidth
+	}
+	return l
+}
+
+// renderSatellite draws a small decorative orb, vertically centered beside
+// the main one. Each side swirls out of phase with the main orb.
+func renderSatellite(l layout, cells *cellBuffer, o orbFrame) string {
+	o.width = l.satelliteWidth
+	o.radius = o.width / 4
+	o.height = o.radius * 2
+	o.ring = noRing
+
+	var lines []string
+	top := (l.visibleHeight - o.height) / 2
+	for y := 0; y < l.visibleHeight; y++ {
+		lines = append(lines, cells.span(o, y-top, 0, o.width))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
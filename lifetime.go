@@ -0,0 +1,64 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"time"
+)
+
+// Sessions disconnected for reaching --max-session-lifetime
+var metricLifetimeDisconnects = expvar.NewInt("lifetime_disconnects")
+
+// How long an ssh or web session may last in all, however busy, set by
+// --max-session-lifetime. 0 lets sessions run as long as they like.
+var maxSessionLifetime = 2 * time.Hour
+
+// How long before the end of a session's lifetime the orb counts it down
+const lifetimeWarning = 5 * time.Minute
+
+// How long the parting prophecy stays up before the session closes
+const partingTime = 15 * time.Second
+
+// lifetimeLeft is how long the session has until its parting prophecy, or
+// false when it may last forever. It goes below zero while parting.
+func (m model) lifetimeLeft(now time.Time) (time.Duration, bool) {
+	if m.session == nil || maxSessionLifetime <= 0 {
+		return 0, false
+	}
+	return maxSessionLifetime - now.Sub(m.started), true
+}
+
+// lifetimeWarningText counts down the last minutes of a session's lifetime
+// and then the parting prophecy.
+func (m model) lifetimeWarningText(now time.Time) string {
+	left, ok := m.lifetimeLeft(now)
+	switch {
+	case !ok || left > lifetimeWarning:
+		return ""
+	case left > 0:
+		return fmt.Sprintf("The orb's time with you draws to a close; a last prophecy comes in %v.", left.Round(time.Second))
+	}
+	return fmt.Sprintf("The orb's time with you is over; it will close in %v.", max((left+partingTime).Round(time.Second), 0))
+}
+
+// part sets aside whatever the session was doing and leaves it with a
+// parting prophecy for its last moments.
+func (m model) part() model {
+	if m.cancelAsk != nil {
+		m.cancelAsk()
+		m.cancelAsk = nil
+	}
+	m.parting = true
+	m.thinking, m.typing, m.streamed = false, false, ""
+	m.focus, m.meditation, m.history, m.themeEditor, m.paths = nil, nil, nil, nil, nil
+	m.notesOpen, m.comparing, m.showingDiff, m.showingNotes = false, false, false, false
+	m.textInput.Blur()
+	m.showingAnswer = true
+	m.question = ""
+	m.answer = randomFortune()
+	m.stats = measureAnswer(m.answer)
+	m.notice = "A parting prophecy"
+	m.footnotes, m.previous = nil, ""
+	m.seal, m.requestID, m.signature = "", "", ""
+	return m
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Questions waiting for a provider slot, by provider
+var metricProviderWaiting = expvar.NewMap("provider_waiting")
+
+// Concurrency caps by provider name, set by --max-concurrent; the "" entry
+// applies to providers not named
+var providerLimits = map[string]int{}
+
+// One limiter per provider name, shared by every chain and route using it
+var (
+	providerLimitersMu sync.Mutex
+	providerLimiters   = map[string]*fairLimiter{}
+)
+
+// parseProviderLimits reads "8" or "ollama=2,openai=4" (or both, "4,ollama=1").
+func parseProviderLimits(list string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, item := range parseList(list) {
+		name, value, named := strings.Cut(item, "=")
+		if !named {
+			name, value = "", item
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid concurrency limit %q", item)
+		}
+		limits[strings.TrimSpace(name)] = n
+	}
+	return limits, nil
+}
+
+// limitProvider caps how many questions the named provider works on at once.
+func limitProvider(name string, provider WisdomProvider) WisdomProvider {
+	n, ok := providerLimits[name]
+	if !ok {
+		n = providerLimits[""]
+	}
+	if n <= 0 {
+		return provider
+	}
+	providerLimitersMu.Lock()
+	defer providerLimitersMu.Unlock()
+	l := providerLimiters[name]
+	if l == nil {
+		l = newFairLimiter(n)
+		providerLimiters[name] = l
+	}
+	return &limitedProvider{name: name, provider: provider, limiter: l}
+}
+
+// fairLimiter hands out a fixed number of slots. When they run out, waiting
+// askers are served round-robin, so one asker with many questions in flight
+// can't starve the rest.
+type fairLimiter struct {
+	mu      sync.Mutex
+	free    int
+	waiting map[string][]chan struct{} // By asker, oldest first
+	turns   []string                   // Askers with someone waiting, next to be served first
+}
+
+func newFairLimiter(slots int) *fairLimiter {
+	return &fairLimiter{free: slots, waiting: make(map[string][]chan struct{})}
+}
+
+// acquire waits for a slot for asker, or until ctx is done.
+func (l *fairLimiter) acquire(ctx context.Context, asker string) error {
+	l.mu.Lock()
+	if l.free > 0 && len(l.turns) == 0 {
+		l.free--
+		l.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	if len(l.waiting[asker]) == 0 {
+		l.turns = append(l.turns, asker)
+	}
+	l.waiting[asker] = append(l.waiting[asker], ready)
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		select {
+		case <-ready:
+			// Granted while giving up; pass the slot on
+			l.handOff()
+		default:
+			l.forget(asker, ready)
+		}
+		return ctx.Err()
+	}
+}
+
+// release returns a slot, handing it to the next asker in turn.
+func (l *fairLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handOff()
+}
+
+// handOff gives a freed slot to the asker whose turn it is. l.mu is held.
+func (l *fairLimiter) handOff() {
+	if len(l.turns) == 0 {
+		l.free++
+		return
+	}
+	asker := l.turns[0]
+	l.turns = l.turns[1:]
+	queue := l.waiting[asker]
+	close(queue[0])
+	if len(queue) > 1 {
+		l.waiting[asker] = queue[1:]
+		l.turns = append(l.turns, asker) // To the back of the line
+	} else {
+		delete(l.waiting, asker)
+	}
+}
+
+// forget drops a waiter that gave up. l.mu is held.
+func (l *fairLimiter) forget(asker string, ready chan struct{}) {
+	queue := l.waiting[asker]
+	for i, ch := range queue {
+		if ch == ready {
+			queue = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) > 0 {
+		l.waiting[asker] = queue
+		return
+	}
+	delete(l.waiting, asker)
+	for i, a := range l.turns {
+		if a == asker {
+			l.turns = append(l.turns[:i], l.turns[i+1:]...)
+			break
+		}
+	}
+}
+
+// limitedProvider waits for a slot before asking the provider.
+type limitedProvider struct {
+	name     string
+	provider WisdomProvider
+	limiter  *fairLimiter
+}
+
+func (p *limitedProvider) wait(ctx context.Context) error {
+	metricProviderWaiting.Add(p.name, 1)
+	defer metricProviderWaiting.Add(p.name, -1)
+	return p.limiter.acquire(ctx, askerFrom(ctx))
+}
+
+func (p *limitedProvider) GetAnswer(ctx context.Context, question string) (string, error) {
+	if err := p.wait(ctx); err != nil {
+		return "", err
+	}
+	defer p.limiter.release()
+	return p.provider.GetAnswer(ctx, question)
+}
+
+// StreamAnswer holds the slot until the stream ends.
+func (p *limitedProvider) StreamAnswer(ctx context.Context, question string, onChunk func(string)) (string, error) {
+	if err := p.wait(ctx); err != nil {
+		return "", err
+	}
+	defer p.limiter.release()
+	if streaming, ok := p.provider.(StreamingProvider); ok {
+		return streaming.StreamAnswer(ctx, question, onChunk)
+	}
+	answer, err := p.provider.GetAnswer(ctx, question)
+	if err == nil {
+		onChunk(answer)
+	}
+	return answer, err
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// lineMode answers ssh exec requests and sessions without a terminal in
+// plain text, so the orb can be consulted from scripts:
+//
+//	ssh ponder.guru ask "should I deploy"
+//	ssh ponder.guru "will it rain?"
+//	ssh ponder.guru ask --json "should I deploy" | jq -r .wisdom
+//	echo "should I deploy" | ssh -T ponder.guru
+//
+// The exit status is 0 for an answer, 1 when the orb is silent and 2 for a
+// command it doesn't understand. Interactive sessions go on to the orb.
+func lineMode() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			if _, _, active := s.Pty(); active && len(s.Command()) == 0 {
+				next(s)
+				return
+			}
+			s.Exit(execCommand(s))
+		}
+	}
+}
+
+// execCommand runs the session's command and returns its exit status. A
+// command that isn't "ask" is taken as the question itself.
+func execCommand(s ssh.Session) int {
+	args := s.Command()
+	if len(args) > 0 && args[0] == "ask" {
+		args = args[1:]
+	}
+	flags := flag.NewFlagSet("ask", flag.ContinueOnError)
+	flags.SetOutput(s.Stderr())
+	persona := flags.String("persona", "", "persona to answer in")
+	asJSON := flags.Bool("json", false, `print {"question", "wisdom", "latency_ms"} as JSON instead of the bare answer`)
+	private := flags.Bool("private", noLog, "keep the question out of the orb's history")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: ssh %s@host ask [flags] question...   (or the question on stdin)\n", s.User())
+		flags.PrintDefaults()
+		if noLog {
+			fmt.Fprintln(flags.Output(), "This orb keeps no record of your questions.")
+		} else {
+			fmt.Fprintln(flags.Output(), "Questions are recorded in the orb's history unless asked with -private.")
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if *persona != "" && !validPersona(*persona) {
+		fmt.Fprintf(s.Stderr(), "unknown persona %q\n", *persona)
+		return 2
+	}
+	if p := sessionEnv(s.Environ(), acceptedEnv)["ORB_PERSONA"]; *persona == "" && validPersona(p) {
+		*persona = p
+	}
+
+	question := strings.Join(flags.Args(), " ")
+	if strings.TrimSpace(question) == "" {
+		line, err := bufio.NewReader(io.LimitReader(s, 64<<10)).ReadString('\n')
+		if err != nil && err != io.EOF {
+			fmt.Fprintf(s.Stderr(), "failed to read question: %v\n", err)
+			return 1
+		}
+		question = line
+	}
+	question = strings.TrimSpace(question)
+	if question == "" {
+		flags.Usage()
+		return 2
+	}
+
+	if wait := questionWait(remoteIP(s)); wait > 0 {
+		fmt.Fprintf(s.Stderr(), "too many questions from your address; try again in %v\n", max(wait.Round(time.Second), time.Second))
+		return 1
+	}
+	asked := time.Now()
+	var onChunk func(string)
+	streamed := false
+	if !*asJSON {
+		// Scripts reading the bare answer get it as it's written
+		onChunk = func(chunk string) {
+			chunk, _ = stripControl(chunk)
+			io.WriteString(s, chunk)
+			streamed = true
+		}
+	}
+	answer, requestID, err := answerLine(s, question, *persona, *private || noLog, onChunk)
+	if streamed {
+		fmt.Fprintln(s)
+	}
+	rec := askRecord{Question: question, Wisdom: answer, LatencyMs: time.Since(asked).Milliseconds()}
+	if err != nil {
+		// The cause is logged; it may name backends the client shouldn't see
+		rec.Error = fmt.Sprintf("the orb is silent: %v [req=%s]", countFailure(err).err, requestID)
+		fmt.Fprintln(s.Stderr(), rec.Error)
+	}
+	if *asJSON {
+		json.NewEncoder(s).Encode(rec)
+	} else if err == nil && !streamed {
+		fmt.Fprintln(s, answer)
+	}
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+// answerLine asks a question for a session without the orb's interface,
+// recording it in the history like any other unless it's private. With
+// onChunk, a provider that streams hands it the answer as it's written.
+func answerLine(s ssh.Session, question, persona string, private bool, onChunk func(string)) (string, string, error) {
+	usage.session()
+	usage.question()
+	client := clientID(s)
+	if delay := abuse.observe(client, question, time.Now()); delay > 0 {
+		time.Sleep(delay)
+	}
+
+	activeSessions.asked(s.Context().SessionID())
+	requestID := newRequestID()
+	meta := &answerMeta{}
+	ctx := withAnswerMeta(withAsker(withRequest(s.Context(), requestID, persona), client), meta)
+	var answer string
+	var err error
+	if streaming, ok := wisdom.(StreamingProvider); ok && onChunk != nil {
+		answer, err = streaming.StreamAnswer(ctx, question, onChunk)
+	} else {
+		answer, err = wisdom.GetAnswer(ctx, question)
+	}
+	rec := historyRecord{Time: time.Now().UTC(), Identity: keyFingerprint(s), RequestID: requestID, Question: question}
+	if err != nil {
+		rec.Answer, rec.Failed = err.Error(), true
+		if !private {
+			recordHistory(rec)
+		}
+		sessionLogger(s).Error("getting answer", "request", requestID, "err", err)
+		return "", requestID, err
+	}
+	answer = cleanAnswer(answer, meta)
+	rec.Answer, rec.Seal = answer, prophecySeal(question, answer)
+	if !private {
+		recordHistory(rec)
+	}
+	return answer, requestID, nil
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Questions the simulated clients take turns asking
+var loadtestQuestions = []string{
+	"Will it rain tomorrow?",
+	"Should I deploy on a Friday?",
+	"Is this the right path?",
+	"What should I have for lunch?",
+	"Will the build be green?",
+}
+
+// Text the server renders once an answer is on screen
+var answerMarker = []byte("Ask another question")
+
+// The background color query the server sends as a session starts, and the
+// reply of a dark terminal. Without a reply the UI waits for a timeout.
+var (
+	backgroundQuery = []byte("\x1b]11;?")
+	terminalReply   = []byte("\x1b]11;rgb:0000/0000/0000\x1b\\\x1b[?62c")
+	altScreen       = []byte("\x1b[?1049h") // The UI is up and reading keys
+)
+
+// How long the answer marker must be gone before the answer counts as dismissed
+const dismissQuiet = 300 * time.Millisecond
+
+// Results collected from a single simulated session
+type loadtestResult struct {
+	latencies []time.Duration
+	frames    int
+	elapsed   time.Duration
+	errs      []string
+}
+
+func runLoadtest(args []string) {
+	fs := flag.NewFlagSet("loadtest", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:2222", "address of the ssh server to test")
+	sessions := fs.Int("sessions", 10, "number of concurrent ssh sessions")
+	duration := fs.Duration("duration", time.Minute, "how long each session stays connected")
+	rampUp := fs.Duration("ramp-up", 10*time.Second, "time over which sessions are started")
+	think := fs.Duration("think", 2*time.Second, "pause between receiving an answer and asking again")
+	answerTimeout := fs.Duration("answer-timeout", 30*time.Second, "how long to wait for an answer before counting an error")
+	fs.Parse(args)
+
+	fmt.Printf("starting %d sessions against %s for %s\n", *sessions, *addr, *duration)
+
+	results := make([]loadtestResult, *sessions)
+	var wg sync.WaitGroup
+	for i := 0; i < *sessions; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if *sessions > 1 {
+				time.Sleep(*rampUp * time.Duration(i) / time.Duration(*sessions))
+			}
+			results[i] = loadtestSession(*addr, *duration, *think, *answerTimeout)
+		}(i)
+	}
+	wg.Wait()
+
+	printLoadtestReport(results)
+
+	for _, r := range results {
+		if len(r.errs) > 0 {
+			os.Exit(1)
+		}
+	}
+}
+
+// loadtestSession connects one headless client, asks questions until the
+// duration runs out, and records how long each answer took to appear.
+func loadtestSession(addr string, duration, think, answerTimeout time.Duration) loadtestResult {
+	var res loadtestResult
+
+	client, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+		User: "loadtest",
+		// The server lets anyone in; answering no questions is enough
+		Auth: []gossh.AuthMethod{gossh.KeyboardInteractive(
+			func(string, string, []string, []bool) ([]string, error) { return nil, nil },
+		)},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		Timeout:         10 * time.Second,
+	})
+	if err != nil {
+		res.errs = append(res.errs, "dial")
+		return res
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		res.errs = append(res.errs, "session")
+		return res
+	}
+	defer session.Close()
+
+	if err := session.RequestPty("xterm-256color", 40, 100, gossh.TerminalModes{}); err != nil {
+		res.errs = append(res.errs, "pty")
+		return res
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		res.errs = append(res.errs, "stdin")
+		return res
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		res.errs = append(res.errs, "stdout")
+		return res
+	}
+	if err := session.Shell(); err != nil {
+		res.errs = append(res.errs, "shell")
+		return res
+	}
+
+	// Every write from the server is roughly one rendered frame; watch them
+	// for the answer marker and signal the asking loop when it shows up.
+	var mu sync.Mutex
+	frames := 0
+	var markerSeen time.Time
+	answered := make(chan struct{}, 1)
+	ready := make(chan struct{})
+	var readyOnce sync.Once
+	markReady := func() { readyOnce.Do(func() { close(ready) }) }
+	go func() {
+		defer markReady()
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := stdout.Read(buf)
+			if n > 0 {
+				mu.Lock()
+				frames++
+				if bytes.Contains(buf[:n], answerMarker) {
+					markerSeen = time.Now()
+					select {
+					case answered <- struct{}{}:
+					default:
+					}
+				}
+				if bytes.Contains(buf[:n], backgroundQuery) {
+					stdin.Write(terminalReply)
+				}
+				if bytes.Contains(buf[:n], altScreen) {
+					markReady()
+				}
+				mu.Unlock()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// Keys typed before the UI is up would be swallowed
+	select {
+	case <-ready:
+	case <-time.After(answerTimeout):
+		res.errs = append(res.errs, "no ui")
+		return res
+	}
+
+	start := time.Now()
+	deadline := start.Add(duration)
+	for time.Now().Before(deadline) {
+		question := loadtestQuestions[rand.Intn(len(loadtestQuestions))]
+		asked := time.Now()
+		mu.Lock()
+		_, err := fmt.Fprintf(stdin, "%s\r", question)
+		mu.Unlock()
+		if err != nil {
+			res.errs = append(res.errs, "write")
+			break
+		}
+
+		select {
+		case <-answered:
+			res.latencies = append(res.latencies, time.Since(asked))
+		case <-time.After(answerTimeout):
+			res.errs = append(res.errs, "answer timeout")
+		}
+
+		time.Sleep(think)
+		// Dismiss the answer to get back to the prompt
+		mu.Lock()
+		_, err = stdin.Write([]byte("\r"))
+		mu.Unlock()
+		if err != nil {
+			res.errs = append(res.errs, "write")
+			break
+		}
+		// The answer is redrawn on every frame until the dismissal lands, so
+		// wait for it to go away and drop the signals it left behind
+		for {
+			mu.Lock()
+			quiet := time.Since(markerSeen) >= dismissQuiet
+			mu.Unlock()
+			if quiet {
+				break
+			}
+			time.Sleep(dismissQuiet / 3)
+		}
+		select {
+		case <-answered:
+		default:
+		}
+	}
+
+	res.elapsed = time.Since(start)
+	mu.Lock()
+	res.frames = frames
+	mu.Unlock()
+	return res
+}
+
+func printLoadtestReport(results []loadtestResult) {
+	var latencies []time.Duration
+	var fps []float64
+	errs := make(map[string]int)
+	for _, r := range results {
+		latencies = append(latencies, r.latencies...)
+		if r.elapsed > 0 {
+			fps = append(fps, float64(r.frames)/r.elapsed.Seconds())
+		}
+		for _, e := range r.errs {
+			errs[e]++
+		}
+	}
+
+	fmt.Printf("\nsessions: %d, answers: %d\n", len(results), len(latencies))
+
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		fmt.Printf("latency  p50=%s p90=%s p99=%s max=%s\n",
+			percentile(latencies, 0.50), percentile(latencies, 0.90),
+			percentile(latencies, 0.99), latencies[len(latencies)-1])
+	}
+
+	if len(fps) > 0 {
+		sort.Float64s(fps)
+		var sum float64
+		for _, f := range fps {
+			sum += f
+		}
+		fmt.Printf("fps      min=%.1f avg=%.1f max=%.1f\n", fps[0], sum/float64(len(fps)), fps[len(fps)-1])
+	}
+
+	if len(errs) == 0 {
+		fmt.Println("errors   none")
+		return
+	}
+	fmt.Println("errors")
+	for kind, n := range errs {
+		fmt.Printf("  %-16s %d\n", kind, n)
+	}
+}
+
+// percentile returns the p-th percentile of an already sorted slice.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i].Round(time.Millisecond)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// setupLogging sends logs to stderr at level and above, as "text" or
+// "json". The standard library's log goes through it too.
+func setupLogging(level, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("unknown log format %q, want text or json", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// fatal logs what stopped the orb from starting and exits.
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
+
+// sessionLogger tags log entries with an ssh session and where it's from.
+func sessionLogger(s ssh.Session) *slog.Logger {
+	id := s.Context().SessionID()
+	if len(id) > 12 {
+		id = id[:12] // Plenty to tell sessions apart
+	}
+	return slog.With("session", id, "remote", s.RemoteAddr().String())
+}
+
+// logSessions logs each ssh session as it connects and disconnects.
+func logSessions() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			logger := sessionLogger(s)
+			pty, _, _ := s.Pty()
+			command := ""
+			if len(s.Command()) > 0 {
+				command = s.Command()[0] // The rest may be a question
+			}
+			logger.Info("connect",
+				"user", s.User(),
+				"key", keyFingerprint(s) != "",
+				"command", command,
+				"term", pty.Term,
+				"window", fmt.Sprintf("%dx%d", pty.Window.Width, pty.Window.Height),
+				"client", s.Context().ClientVersion())
+			start := time.Now()
+			next(s)
+			logger.Info("disconnect", "duration", time.Since(start))
+		}
+	}
+}
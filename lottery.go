@@ -0,0 +1,22 @@
+package main
+
+import "math/rand"
+
+// Curated questions the orb may choose to ask on the user's behalf
+var lotteryQuestions = []string{
+	"What am I not seeing?",
+	"What should I let go of?",
+	"Where will I be in a year?",
+	"What deserves more of my attention?",
+	"Is now the time to begin?",
+	"What is the lesson in my current trouble?",
+	"Whom should I reach out to?",
+	"What would my younger self tell me?",
+	"Am I asking the right question?",
+	"What small thing will matter most this week?",
+}
+
+// lotteryQuestion lets the orb choose a question.
+func lotteryQuestion() string {
+	return lotteryQuestions[rand.Intn(len(lotteryQuestions))]
+}
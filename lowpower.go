@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Whether the orb saves energy with fewer frames, no ambient effects and
+// duller colors. Set by --low-power, or in local mode by running on battery.
+var lowPower = false
+
+// The most frames per second drawn in low-power mode
+const lowPowerFPS = 5
+
+// How much of its saturation a color keeps in low-power mode
+const lowPowerSaturation = 0.5
+
+// onBattery reports whether this machine is running from its battery, where
+// that can be told.
+func onBattery() bool {
+	switch runtime.GOOS {
+	case "linux":
+		supplies, _ := filepath.Glob("/sys/class/power_supply/*")
+		for _, dir := range supplies {
+			kind, _ := os.ReadFile(filepath.Join(dir, "type"))
+			status, _ := os.ReadFile(filepath.Join(dir, "status"))
+			if strings.TrimSpace(string(kind)) == "Battery" && strings.TrimSpace(string(status)) == "Discharging" {
+				return true
+			}
+		}
+	case "darwin":
+		out, err := exec.Command("pmset", "-g", "batt").Output()
+		return err == nil && strings.Contains(string(out), "'Battery Power'")
+	}
+	return false
+}
+
+// frameRate is how many frames per second the orb is drawn at.
+func frameRate() int {
+	if lowPower {
+		return min(animationFPS, lowPowerFPS)
+	}
+	return animationFPS
+}
+
+// ambientFrame is the frame ambient effects, like the header's scrolling
+// gradient and the orb's drifting hue, are drawn at. They hold still in
+// low-power mode.
+func ambientFrame(frame int) int {
+	if lowPower {
+		return 0
+	}
+	return frame
+}
+
+// mute pulls a #rrggbb color toward the grey of the same brightness,
+// keeping keep of its saturation.
+func mute(c lipgloss.Color, keep float64) lipgloss.Color {
+	r, g, b, ok := hexRGB(string(c))
+	if !ok {
+		return c
+	}
+	grey := uint8((int(r) + int(g) + int(b)) / 3)
+	return blendColor(lipgloss.Color(fmt.Sprintf("#%02X%02X%02X", grey, grey, grey)), c, keep)
+}
+
+// mute dulls all of the orb's colors.
+func (c *orbColors) mute(keep float64) {
+	for i := range c.palette {
+		c.palette[i] = mute(c.palette[i], keep)
+	}
+	for i := range c.header {
+		c.header[i] = mute(c.header[i], keep)
+	}
+	c.rim = mute(c.rim, keep)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The command that defines a macro for the session, removes one or lists
+// them:
+//
+//	/macro tl;dr Summarize in one sentence:
+//	/macro tl;dr
+//	/macro
+const macroCommand = "/macro"
+
+// What a question starts with to use a macro: "!tl;dr why is the sky blue"
+const macroPrefix = "!"
+
+// Macros every session starts with, from the [macros] section of the
+// config file:
+//
+//	[macros]
+//	"tl;dr" = "Summarize in one sentence:"
+var configMacros = map[string]string{}
+
+// takeMacros removes the [macros] section from config settings, since
+// macros aren't flags, and returns the macros it defines.
+func takeMacros(values map[string]string) map[string]string {
+	macros := make(map[string]string)
+	for key, value := range values {
+		name, ok := strings.CutPrefix(key, "macros.")
+		if !ok {
+			continue
+		}
+		delete(values, key)
+		if unquoted, err := strconv.Unquote(name); err == nil {
+			name = unquoted
+		}
+		macros[name] = value
+	}
+	return macros
+}
+
+// expandMacro replaces a macro at the start of a question with what it
+// stands for, reporting whether there was one.
+func expandMacro(question string, macros map[string]string) (string, bool) {
+	word, rest, _ := strings.Cut(question, " ")
+	name, ok := strings.CutPrefix(word, macroPrefix)
+	if !ok {
+		return question, false
+	}
+	expansion, ok := macros[name]
+	if !ok {
+		return question, false
+	}
+	return strings.TrimSpace(strings.TrimSpace(expansion) + " " + strings.TrimSpace(rest)), true
+}
+
+// runMacroCommand applies a /macro command to the session's macros and
+// returns what to tell the asker.
+func runMacroCommand(command string, macros map[string]string) (string, error) {
+	args := strings.TrimSpace(strings.TrimPrefix(command, macroCommand))
+	if args == "" {
+		if len(macros) == 0 {
+			return "no macros yet; /macro name expansion defines one", nil
+		}
+		names := slices.Sorted(maps.Keys(macros))
+		for i, name := range names {
+			names[i] = macroPrefix + name
+		}
+		return "macros: " + strings.Join(names, ", "), nil
+	}
+
+	name, expansion, _ := strings.Cut(args, " ")
+	name = strings.TrimPrefix(name, macroPrefix)
+	expansion = strings.TrimSpace(expansion)
+	if name == "" {
+		return "", errors.New("usage: /macro name expansion")
+	}
+	if expansion == "" {
+		if _, ok := macros[name]; !ok {
+			return "", fmt.Errorf("no macro %s%s to remove", macroPrefix, name)
+		}
+		delete(macros, name)
+		return fmt.Sprintf("%s%s removed", macroPrefix, name), nil
+	}
+	macros[name] = expansion
+	return fmt.Sprintf("%s%s now stands for %q", macroPrefix, name, expansion), nil
+}
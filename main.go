@@ -1,28 +1,35 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"maps"
 	"math"
 	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 	"unicode/utf8"
 
+	"github.com/charmbracelet/bubbles/cursor"
 	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/ssh"
 	"github.com/charmbracelet/wish"
 	"github.com/charmbracelet/wish/bubbletea"
-	"github.com/charmbracelet/wish/logging"
-	"github.com/muesli/termenv"
 )
 
 const header = `
@@ -40,24 +47,26 @@ const header = `
 type tickMsg time.Time
 
 // A message with the answer from the cosmos
-type answerMsg struct{ answer string }
+type answerMsg struct {
+	answer   string
+	expires  time.Time
+	fortune  bool     // Told from the fortune database because the provider failed
+	provider string   // Which provider of a fallback chain answered, if any
+	notes    []string // What was done to the question and answer along the way
+}
 
 // A message for when things go wrong
 type errMsg struct{ err error }
 
-// JSON struct for the request payload
-type questionPayload struct {
-	Question string `json:"question"`
-}
+// The question was interrupted with Esc before an answer came
+type interruptedMsg struct{}
 
-// JSON structs for parsing the response
-type wisdomResponse struct {
-	Wisdom string `json:"wisdom"`
-}
+// Frames drawn per second, set by --animation-fps
+var animationFPS = 20
 
 // The command to produce the tickMsg at a regular interval
-func tickCmd() tea.Cmd {
-	return tea.Tick(time.Millisecond*50, func(t time.Time) tea.Msg {
+func (m model) tickCmd() tea.Cmd {
+	return tea.Tick(m.frameInterval(), func(t time.Time) tea.Msg {
 		return tickMsg(t)
 	})
 }
@@ -70,90 +79,696 @@ type model struct {
 	textInput     textinput.Model
 	spinner       spinner.Model
 	thinking      bool
+	listening     bool // Waiting on the speech-to-text command
 	showingAnswer bool
+	question      string
 	answer        string
+	seal          string      // Prophecy seal of the current answer, empty for errors
+	signature     string      // Server signature of the current answer, empty when unsigned
+	stats         answerStats // Length, language and reading time of the current answer
+	answeredBy    string      // Provider of a fallback chain that gave the current answer
 	renderer      *lipgloss.Renderer
+	quick         bool              // Minimal single-question UI that quits after answering
+	inline        bool              // Small orb rendered in the scrollback instead of the alt screen
+	env           map[string]string // Client environment accepted for this session
+	theme         theme
+	themeName     string     // Name the theme was chosen by, "" for an unsaved edit
+	themeShift    themeShift // Eases the orb from its last theme into this one
+	persona       string
+	speechCommand string // Speech-to-text command for /speak, local mode only
+	mood          mood   // Palette bias left behind by the last answer
+	meditation    *meditation
+	focus         *focusSession
+	themeEditor   *themeEditor       // Live-previewing a theme being edited
+	history       *historyBrowser    // Browsing earlier questions
+	recall        questionRecall     // Earlier questions for the up and down keys
+	answerPort    viewport.Model     // Scrolls answers too long for the orb
+	footnotes     []string           // What filters and fallbacks did to the current answer
+	showingNotes  bool               // The footnotes are open
+	conversation  []exchange         // Recent exchanges, sent along for follow-up questions
+	handoffCode   string             // Code another session can take this one over with
+	cancelAsk     context.CancelFunc // Interrupts the question being pondered
+	cooldown      *cooldown          // A rate limited backend is resting before the next question
+	recorder      *frameRecorder     // Recent frames for clips, local mode only
+	user          string             // Who is pondering, for per-user stats
+	identity      string             // Verified public key fingerprint in ssh sessions, the OS user locally
+	client        string             // Who is asking in an ssh session, for abuse heuristics
+	addr          string             // IP address of an ssh or web client, for per-IP limits
+	session       context.Context    // Canceled when an SSH client disconnects, nil locally
+	sessionID     string             // What activeSessions knows the session by, "" locally
+	notice        string             // Shown above the answer, e.g. for delayed deliveries
+	requestID     string             // Traces the current question through logs and backends
+	sent          string             // The current question as sent, notes and all
+	retry         retryKey           // The last failed question, so asking it again reuses its request ID
+	notes         textarea.Model     // Scratchpad for thoughts between questions
+	notesOpen     bool
+	attachNotes   bool   // Send the notes along with the next question
+	previous      string // Earlier, different answer to the same question
+	showingDiff   bool
+	expires       time.Time // When the current answer goes stale
+	streamed      string    // Answer text received so far while streaming
+	typing        bool      // The typewriter is revealing a streamed answer
+	revealed      int       // Runes of the answer the typewriter has shown
+	streamFrames  int       // Frames since the first chunk, for the spinner fade
+	comparing     bool      // Showing answers from two backends side by side
+	comparison    [2]string
+	preferred     string    // "A" or "B" once the user has picked
+	shownTitle    string    // Last state announced in the terminal title
+	lastActive    time.Time // Last key press, or when the orb was last busy
+	started       time.Time // When the session began, for --max-session-lifetime
+	parting       bool      // Showing the parting prophecy before the session closes
+	answerLayout  textLayout
+	cells         *cellBuffer       // The orbs as last drawn, so frames restyle only what changed
+	logger        *slog.Logger      // Tags log entries with the session they're about
+	hidden        bool              // Answer and input covered while the screen is shared
+	private       bool              // Questions are kept out of the history, toggled with privateKey
+	macros        map[string]string // Question prefixes the session can expand, by name
+	pathsWanted   bool              // The next question is asked with /paths
+	paths         []string          // Answers offered to choose between, nil when none are
+	pathNotes     []string          // What was done to the question and answers along the way
+	banner        string            // Shown before the orb appears, until a key is pressed
+	bannerUntil   time.Time
+	still         bool           // Not animated, for reduced motion
+	throttle      *frameThrottle // Fits an ssh session's frame rate to its link
 }
 
 func initialModel() model {
 	ti := textinput.New()
-	ti.Placeholder = ""
+	ti.Placeholder = deploymentFlavor.placeholder
 	ti.Focus()
-	ti.CharLimit = 200
+	ti.CharLimit = maxQuestionRunes
 	ti.Prompt = ""
 	ti.TextStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFF")).Background(lipgloss.Color("#222"))
+	if noAnimation {
+		ti.Cursor.SetMode(cursor.CursorStatic)
+	}
 
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("155"))
 
+	t, name := startTheme()
 	return model{
+		notes:         newNotesArea(),
+		theme:         t,
+		themeName:     name,
+		answerLayout:  defaultTextLayout,
+		lastActive:    time.Now(),
+		started:       time.Now(),
+		cells:         newCellBuffer(),
+		logger:        slog.Default(),
+		macros:        maps.Clone(configMacros),
 		textInput:     ti,
 		spinner:       s,
 		thinking:      false,
 		showingAnswer: false,
 		frame:         rand.Intn(1080), // Randomize starting frame for color
+		still:         noAnimation,
+	}
+}
+
+// useRenderer styles the model for a remote terminal's renderer.
+func (m *model) useRenderer(renderer *lipgloss.Renderer) {
+	m.renderer = renderer
+	m.textInput.TextStyle = renderer.NewStyle().Foreground(lipgloss.Color("#FFF")).Background(lipgloss.Color("#222"))
+	m.spinner.Style = renderer.NewStyle().Foreground(lipgloss.Color("155"))
+}
+
+// applyPreferences picks up ORB_THEME, ORB_PERSONA and the answer layout
+// from the client's environment, ignoring values that don't name an enabled
+// option.
+func (m *model) applyPreferences(env map[string]string) {
+	m.answerLayout = m.answerLayout.apply(env)
+	if t, ok := lookupTheme(env["ORB_THEME"]); ok {
+		m.theme, m.themeName = t, env["ORB_THEME"]
+	}
+	if p := env["ORB_PERSONA"]; validPersona(p) {
+		m.persona = p
+		m.textInput.Placeholder = flavorFor(p).placeholder
 	}
 }
 
 func (m model) Init() tea.Cmd {
-	return tea.Batch(tickCmd(), textinput.Blink)
+	return tea.Batch(m.tickCmd(), textinput.Blink)
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	return announceState(m.update(msg))
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 	var cmd tea.Cmd
 
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
-		m.width = msg.Width
-		m.height = msg.Height
+		m.width, m.height = clampSize(msg.Width, msg.Height)
 		return m, nil
 
 	case tea.KeyMsg:
-		if m.thinking {
-			return m, nil // Ignore key presses when thinking
+		m.lastActive = time.Now()
+		if m.parting {
+			if msg.String() == "ctrl+c" {
+				return m.quit()
+			}
+			return m, nil // Nothing more is asked once the orb is parting
+		}
+		if m.banner != "" {
+			if msg.String() == "ctrl+c" {
+				return m.quit()
+			}
+			m.banner = ""
+			return m, nil
+		}
+		if msg.String() == hideKey {
+			m.hidden = !m.hidden
+			if m.hidden {
+				usage.feature("hide")
+			}
+			return m, nil
+		}
+		if msg.String() == stillKey {
+			return m.toggleStill()
+		}
+		if m.hidden && msg.String() != "ctrl+c" {
+			return m, nil // Nothing is typed or answered blind
+		}
+		if msg.String() == "ctrl+n" {
+			return m.toggleNotes()
+		}
+		if m.notesOpen {
+			switch msg.String() {
+			case "ctrl+c":
+				return m.quit()
+			case "ctrl+t":
+				m.attachNotes = !m.attachNotes
+				return m, nil
+			}
+			m.notes, cmd = m.notes.Update(msg)
+			return m, cmd
+		}
+		if m.thinking && msg.String() == "esc" && m.cancelAsk != nil {
+			return m.interrupt()
+		}
+		if m.thinking || m.listening {
+			return m, nil // Ignore other key presses when thinking
+		}
+		if m.focus != nil {
+			switch msg.String() {
+			case "ctrl+c":
+				return m.quit()
+			case "esc":
+				m.focus = nil
+				m.textInput.Focus()
+				return m, textinput.Blink
+			}
+			return m, nil
+		}
+		if m.meditation != nil {
+			switch msg.String() {
+			case "ctrl+c":
+				return m.quit()
+			case "esc", "enter":
+				m.meditation = nil
+				m.textInput.Focus()
+				return m, textinput.Blink
+			}
+			return m, nil
+		}
+		if m.paths != nil {
+			return m.choosePath(msg)
+		}
+		if m.themeEditor != nil {
+			return m.editTheme(msg)
+		}
+		if m.history != nil {
+			return m.browseHistory(msg)
 		}
 		switch msg.String() {
 		case "ctrl+c":
-			return m, tea.Quit
+			return m.quit()
+		case themeKey:
+			usage.feature("theme_switch")
+			m.nextTheme()
+			if !m.showingAnswer {
+				m.textInput.Placeholder = "theme: " + m.themeName
+			}
+			return m, nil
+		case privateKey:
+			if !noLog {
+				m.private = !m.private
+				if m.private {
+					usage.feature("private")
+				}
+			}
+			return m, nil
+		case "ctrl+e":
+			// Like the pager, the editor runs on this machine
+			if !m.showingAnswer && m.session == nil {
+				return m, openEditorCmd(m.textInput.Value())
+			}
+		case "d":
+			if m.showingAnswer && m.previous != "" {
+				usage.feature("diff")
+				m.showingDiff = !m.showingDiff
+				return m, nil
+			}
+		case "r":
+			// Re-ask a question whose answer has gone stale
+			if m.showingAnswer && m.stale() && m.question != "" {
+				m.showingAnswer = false
+				m.notice = ""
+				return m.ask(m.question)
+			}
+		case "f":
+			if m.showingAnswer && len(m.footnotes) > 0 {
+				usage.feature("footnotes")
+				m.showingNotes = !m.showingNotes
+				return m, nil
+			}
+		case "c":
+			// Save the moment the orb spoke
+			if m.showingAnswer && m.recorder != nil {
+				usage.feature("clip")
+				return m, saveClipCmd(m.recorder.clip(), m.width, m.height)
+			}
+		case "p":
+			// The pager runs on this machine, so it's only offered locally
+			if m.showingAnswer && !m.comparing && m.session == nil {
+				usage.feature("pager")
+				return m, openPagerCmd(m.question + "\n\n" + m.answerLayout.export(m.answer))
+			}
+		case "t":
+			// Like the pager, the todo file is on this machine
+			if m.showingAnswer && !m.comparing && m.session == nil && todoPath != "" && m.seal != "" {
+				usage.feature("todo")
+				return m, addTodoCmd(todoPath, m.question, m.answer)
+			}
+		case "a", "b":
+			if m.comparing && m.showingAnswer && m.preferred == "" {
+				m.preferred = strings.ToUpper(msg.String())
+				if !m.recording() {
+					return m, nil
+				}
+				if err := recordPreference(m.question, m.comparison, m.preferred); err != nil {
+					m.logger.Error("recording preference", "err", err)
+				}
+				return m, nil
+			}
 		case "enter":
 			if m.showingAnswer {
+				if m.quick {
+					return m, tea.Quit
+				}
 				m.showingAnswer = false
+				m.comparing = false
+				m.previous = ""
+				m.showingDiff = false
+				m.notice = ""
 				m.textInput.Focus()
 				return m, textinput.Blink
-			} else if m.textInput.Value() != "" {
-				logToFile(m.textInput.Value())
-				m.thinking = true
+			} else if strings.HasPrefix(m.textInput.Value(), "/focus") {
+				f, err := parseFocus(m.textInput.Value())
+				m.textInput.Reset()
+				if err != nil {
+					m.textInput.Placeholder = err.Error()
+					return m, nil
+				}
+				usage.feature("focus")
+				m.focus = &f
+				m.textInput.Blur()
+				return m, nil
+			} else if strings.HasPrefix(m.textInput.Value(), themeCommand) {
+				e, err := parseTheme(m.textInput.Value(), m.theme)
+				m.textInput.Reset()
+				if err != nil {
+					m.textInput.Placeholder = err.Error()
+					return m, nil
+				}
+				usage.feature("theme")
+				m.themeEditor = &e
+				m.textInput.Blur()
+				return m, nil
+			} else if strings.HasPrefix(m.textInput.Value(), "/breathe") {
+				md, err := parseBreathe(m.textInput.Value())
+				m.textInput.Reset()
+				if err != nil {
+					m.textInput.Placeholder = err.Error()
+					return m, nil
+				}
+				usage.feature("breathe")
+				m.meditation = &md
+				m.textInput.Blur()
+				return m, nil
+			} else if strings.HasPrefix(m.textInput.Value(), pathsCommand) {
+				question := strings.TrimSpace(strings.TrimPrefix(m.textInput.Value(), pathsCommand))
+				if question == "" {
+					m.textInput.Reset()
+					m.textInput.Placeholder = "usage: " + pathsCommand + " question"
+					return m, nil
+				}
+				if m.cooldown != nil {
+					return m, nil // Wait out the arc
+				}
+				usage.feature("paths")
+				m.recall.remember(m.textInput.Value())
+				m.pathsWanted = true
+				question, _ = expandMacro(question, m.macros)
+				return m.ask(question)
+			} else if strings.HasPrefix(m.textInput.Value(), macroCommand) {
+				reply, err := runMacroCommand(m.textInput.Value(), m.macros)
+				m.textInput.Reset()
+				if err != nil {
+					reply = err.Error()
+				}
+				usage.feature("macro")
+				m.textInput.Placeholder = reply
+				return m, nil
+			} else if isHandoff(m.textInput.Value()) {
+				usage.feature("handoff")
+				return m.handoff(m.textInput.Value())
+			} else if m.textInput.Value() == speakCommand && m.speechCommand != "" {
+				usage.feature("speak")
+				m.listening = true
+				m.textInput.Reset()
 				m.textInput.Blur()
 				return m, tea.Batch(
 					tea.Tick(time.Second/10, func(t time.Time) tea.Msg { return spinner.TickMsg{} }),
-					getAnswerCmd(m.textInput.Value()),
+					listenCmd(m.speechCommand),
 				)
+			} else if m.textInput.Value() != "" {
+				if m.cooldown != nil {
+					return m, nil // Wait out the arc
+				}
+				m.recall.remember(m.textInput.Value())
+				question, _ := expandMacro(m.textInput.Value(), m.macros)
+				return m.ask(question)
+			}
+		case "up", "down", "pgup", "pgdown":
+			if m.showingAnswer && !m.comparing && !m.showingDiff {
+				// Scroll an answer too long for the orb
+				m.fitAnswer()
+				m.answerPort, cmd = m.answerPort.Update(msg)
+				return m, cmd
+			}
+			if !m.showingAnswer && (msg.String() == "up" || msg.String() == "down") {
+				return m.recallQuestion(msg.String()), nil
+			}
+		case "ctrl+l":
+			// Start a new conversation
+			if !m.showingAnswer && len(m.conversation) > 0 {
+				usage.feature("new_conversation")
+				m.conversation = nil
+				m.textInput.Placeholder = "the orb lets the earlier questions go"
+				return m, nil
+			}
+		case "ctrl+o":
+			if !m.showingAnswer {
+				usage.feature("history")
+				return m.openHistory()
 			}
+		case "ctrl+r":
+			// Oracle roulette: let the orb choose the question
+			if !m.showingAnswer {
+				usage.feature("roulette")
+				question := lotteryQuestion()
+				m.textInput.SetValue(question)
+				return m.ask(question)
+			}
+		}
+
+	case streamChunkMsg:
+		if !m.thinking {
+			return m, nil // Dismissed or superseded while streaming
 		}
+		text, _ := stripControl(msg.text)
+		m.streamed += text
+		m.typing = true
+		return m, msg.next
+
+	case interruptedMsg:
+		return m, nil
 
 	case answerMsg:
+		if !m.thinking {
+			return m, nil // Interrupted, but the answer was already on its way
+		}
 		m.thinking = false
 		m.showingAnswer = true
 		m.answer = msg.answer
+		m.streamed = ""
+		fresh := len(m.conversation) == 0
+		if msg.fortune {
+			m.notice = "The cosmos is out of reach; the orb recalls an old fortune"
+		} else {
+			m.conversation = converse(m.conversation, m.question, m.answer)
+		}
+		m.expires = msg.expires
+		m.answeredBy = msg.provider
+		m.footnotes = append(m.footnotes, msg.notes...)
+		m.seal = prophecySeal(m.question, m.answer)
+		m.signature = signAnswer(m.question, m.answer)
+		m.stats = measureAnswer(m.answer)
+		m.mood = newMood(m.answer)
+		m.previous = ""
+		if previous, changed := recentAnswers.remember(m.identity, m.question, m.answer); changed {
+			m.previous = previous
+		}
+		m.textInput.Reset()
+		if m.recording() {
+			rec := m.historyRecord()
+			rec.Fresh = fresh
+			recordHistory(rec)
+		}
+		if m.inline {
+			// Leave the exchange in the terminal history above the orb
+			seal := m.seal
+			if m.signature != "" {
+				seal += " " + m.signature
+			}
+			return m, tea.Printf("? %s\n%s\n%s\n", m.question, m.answer, seal)
+		}
+		return m, nil
+
+	case handoffClaimedMsg:
+		// Another session took over; this one goes quiet
+		m.handoffCode = ""
+		msg.reply <- m.handoffState()
+		return m.quit()
+
+	case handoffExpiredMsg:
+		if msg.code == m.handoffCode {
+			m.handoffCode = ""
+		}
+		return m, nil
+
+	case handoffMsg:
+		if msg.err != nil {
+			m.textInput.Placeholder = "the orb could not find it: " + msg.err.Error()
+			return m, nil
+		}
+		m.takeOver(msg.state)
+		return m, nil
+
+	case clipSavedMsg:
+		if msg.err != nil {
+			m.logger.Error("saving clip", "err", msg.err)
+			m.notice = "The moment slipped away: " + msg.err.Error()
+		} else {
+			m.notice = "The moment is kept in " + msg.path + " (play it with asciinema play)"
+		}
+		return m, nil
+
+	case todoAddedMsg:
+		if msg.err != nil {
+			m.logger.Error("adding todo", "err", msg.err)
+			m.notice = "The task slipped away: " + msg.err.Error()
+		} else {
+			m.notice = "Added to your tasks in " + msg.path
+		}
+		return m, nil
+
+	case editorDoneMsg:
+		if msg.err != nil {
+			m.logger.Error("running editor", "err", msg.err)
+		} else {
+			m.textInput.SetValue(msg.text)
+			m.textInput.CursorEnd()
+		}
+		return m, nil
+
+	case pagerDoneMsg:
+		if msg.path != "" {
+			os.Remove(msg.path)
+		}
+		if msg.err != nil {
+			m.logger.Error("running pager", "err", msg.err)
+		}
+		return m, nil
+
+	case pathsMsg:
+		if !m.thinking {
+			return m, nil
+		}
+		if len(msg.answers) == 1 {
+			// Nothing to choose between
+			return m.update(answerMsg{answer: msg.answers[0], notes: msg.notes})
+		}
+		m.thinking = false
+		m.paths, m.pathNotes = msg.answers, msg.notes
+		m.textInput.Reset()
+		return m, nil
+
+	case comparisonMsg:
+		if !m.thinking {
+			return m, nil
+		}
+		m.thinking = false
+		m.showingAnswer = true
+		m.comparing = true
+		m.comparison = msg.answers
+		m.preferred = ""
+		m.answer = ""
+		m.seal = ""
+		m.signature = ""
 		m.textInput.Reset()
 		return m, nil
 
+	case transcriptionMsg:
+		// Put the transcription in the prompt so it can be edited before sending
+		m.listening = false
+		if msg.err != nil {
+			m.logger.Error("transcribing question", "err", msg.err)
+			m.textInput.Placeholder = "The orb could not hear you."
+		} else {
+			m.textInput.SetValue(msg.text)
+		}
+		m.textInput.Focus()
+		return m, textinput.Blink
+
 	case errMsg:
+		if !m.thinking {
+			return m, nil
+		}
+		var limited *rateLimitError
+		if errors.As(msg.err, &limited) {
+			// The rim shows the wait; the question stays to be sent again
+			m.logger.Warn("rate limited", "request", m.requestID, "err", msg.err)
+			m.thinking = false
+			m.streamed, m.typing = "", false
+			m.cooldown = &cooldown{started: time.Now(), duration: limited.retryAfter}
+			m.retry = retryKey{sent: m.sent, requestID: m.requestID}
+			m.textInput.SetValue(m.question)
+			m.textInput.CursorEnd()
+			m.textInput.Focus()
+			return m, textinput.Blink
+		}
 		m.thinking = false
 		m.showingAnswer = true
-		m.answer = "The cosmos is silent. Your question remains unanswered."
+		m.answer = classifyFailure(msg.err).failureWords(m.persona)
+		m.streamed = ""
+		m.typing = false
+		m.seal = ""
+		m.signature = ""
+		m.retry = retryKey{sent: m.sent, requestID: m.requestID}
+		m.stats = answerStats{}
 		m.textInput.Reset()
-		log.Printf("Error getting answer: %v", msg.err) // Log error
+		m.logger.Error("getting answer", "request", m.requestID, "err", msg.err)
+		if m.recording() {
+			rec := m.historyRecord()
+			rec.Failed = true
+			recordHistory(rec)
+		}
+		if m.inline {
+			return m, tea.Printf("? %s\n%s\n", m.question, m.answer)
+		}
 		return m, nil
 
 	case tickMsg: // For orb animation
-		m.frame++
-		cmds = append(cmds, tickCmd())
+		if m.throttle != nil && m.throttle.adjust(time.Time(msg)) {
+			m.logger.Debug("fitting frame rate to the link", "fps", m.throttle.fps, "write_latency", m.throttle.meter.writeLatency())
+		}
+		if m.animating() {
+			m.frame++
+		}
+		cmds = append(cmds, m.tickCmd())
+		if m.busy() {
+			m.lastActive = time.Now()
+		}
+		if m.banner != "" && time.Now().After(m.bannerUntil) {
+			m.banner = ""
+		}
+		if m.session != nil && serverClosing.Load() {
+			return m.quit()
+		}
+		if left, ok := m.idleLeft(time.Now()); ok && left <= 0 {
+			metricIdleDisconnects.Add(1)
+			m.logger.Info("disconnecting idle session", "idle", idleTimeout)
+			return m.quit()
+		}
+		if left, ok := m.lifetimeLeft(time.Now()); ok && left <= 0 {
+			if left <= -partingTime {
+				metricLifetimeDisconnects.Add(1)
+				m.logger.Info("closing session at the end of its lifetime", "lifetime", maxSessionLifetime)
+				return m.quit()
+			}
+			if !m.parting {
+				m = m.part()
+			}
+		}
+		if m.typing {
+			m.streamFrames++
+			target := utf8.RuneCountInString(m.answer)
+			if m.thinking {
+				target = utf8.RuneCountInString(m.streamed)
+			}
+			m.revealed = approach(m.revealed, target, typewriterRunesPerTick, typewriterCatchUp)
+			if !m.thinking && m.revealed == target {
+				m.typing = false
+			}
+		}
+		if m.focus != nil && m.focus.done(time.Now()) {
+			f := *m.focus
+			m.focus = nil
+			// Sessions without a verified identity can't be told apart, so
+			// they keep no stats
+			if m.identity != "" {
+				if _, err := recordFocus(m.identity, f.duration); err != nil {
+					m.logger.Error("recording focus session", "err", err)
+				}
+			}
+			// Keep the animation ticking alongside the question
+			m, cmd = m.ask(f.prophecyQuestion())
+			return m, tea.Batch(append(cmds, cmd)...)
+		}
+		if m.cooldown != nil && m.cooldown.done(time.Now()) {
+			m.cooldown = nil
+		}
+		if m.meditation != nil && m.meditation.done(time.Now()) {
+			m.meditation = nil
+			if showAphorism {
+				m.showingAnswer = true
+				m.question = ""
+				m.answer = closingAphorism()
+				m.seal = ""
+				m.requestID = ""
+				m.signature = ""
+			} else {
+				m.textInput.Focus()
+			}
+		}
+		if overlay != nil {
+			state := overlayState{frame: m.frame, theme: m.theme, question: m.question, answer: m.answer}
+			if m.hidden {
+				state.question, state.answer = "", ""
+			}
+			overlay.publish(state)
+		}
 	}
 
-	if m.thinking {
+	if _, tick := msg.(spinner.TickMsg); tick && m.still {
+		// Let the spinner stop; toggleStill starts it again
+	} else if m.thinking || m.listening {
 		m.spinner, cmd = m.spinner.Update(msg)
 		cmds = append(cmds, cmd)
 	} else if !m.showingAnswer {
@@ -164,57 +779,207 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
-// --- View and Rendering Logic ---
-
-func getAnswerCmd(question string) tea.Cmd {
-	return func() tea.Msg {
-		answer, err := getAnswer(question)
-		if err != nil {
-			return errMsg{err}
+// editTheme handles keys while the theme editor is open.
+func (m model) editTheme(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m.quit()
+	case "up", "k":
+		m.themeEditor.move(-1)
+	case "down", "j":
+		m.themeEditor.move(1)
+	case "left", "h":
+		m.setTheme(m.themeEditor.adjust(m.theme, -1))
+	case "right", "l":
+		m.setTheme(m.themeEditor.adjust(m.theme, 1))
+	case "esc":
+		m.setTheme(m.themeEditor.original)
+		m.themeEditor = nil
+		m.textInput.Focus()
+		return m, textinput.Blink
+	case "enter":
+		m.textInput.Placeholder = "theme \"" + m.themeEditor.name + "\" saved, choose it with ORB_THEME"
+		m.themeName = m.themeEditor.name
+		if err := saveTheme(m.themeEditor.name, m.theme); err != nil {
+			m.logger.Error("saving theme", "err", err)
+			m.textInput.Placeholder = "theme kept for this visit: " + err.Error()
+			m.themeName = ""
 		}
-		return answerMsg{answer}
+		m.themeEditor = nil
+		m.textInput.Focus()
+		return m, textinput.Blink
 	}
+	return m, nil
 }
 
-func getAnswer(question string) (string, error) {
-	payload := questionPayload{Question: question}
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal question: %w", err)
-	}
+// stale reports whether the answer on screen has outlived its expiry.
+func (m model) stale() bool {
+	return !m.expires.IsZero() && time.Now().After(m.expires)
+}
 
-	resp, err := http.Post("https://orb.ponder.guru/", "application/json", bytes.NewBuffer(payloadBytes))
-	if err != nil {
-		return "", fmt.Errorf("failed to get wisdom: %w", err)
+// quit saves the scratchpad and ends the program.
+func (m model) quit() (tea.Model, tea.Cmd) {
+	if err := saveNotes(m.identity, m.notes.Value()); err != nil {
+		m.logger.Error("saving notes", "err", err)
 	}
-	defer resp.Body.Close()
+	return m, tea.Quit
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("wisdom API returned non-200 status: %d", resp.StatusCode)
+// toggleNotes opens or closes the scratchpad, saving it on close.
+func (m model) toggleNotes() (tea.Model, tea.Cmd) {
+	m.notesOpen = !m.notesOpen
+	if m.notesOpen {
+		usage.feature("notes")
+		m.textInput.Blur()
+		return m, m.notes.Focus()
+	}
+	m.notes.Blur()
+	if err := saveNotes(m.identity, m.notes.Value()); err != nil {
+		m.logger.Error("saving notes", "err", err)
 	}
+	if !m.thinking && !m.showingAnswer {
+		m.textInput.Focus()
+		return m, textinput.Blink
+	}
+	return m, nil
+}
 
-	var wisdomResp wisdomResponse
-	if err := json.NewDecoder(resp.Body).Decode(&wisdomResp); err != nil {
-		return "", fmt.Errorf("failed to decode wisdom response: %w", err)
+// ask sends a question to the cosmos and switches to the thinking state.
+func (m model) ask(question string) (model, tea.Cmd) {
+	if wait := questionWait(m.addr); wait > 0 {
+		// Too many questions from this address; the rim shows the wait
+		m.cooldown = &cooldown{started: time.Now(), duration: wait}
+		m.textInput.SetValue(question)
+		m.textInput.CursorEnd()
+		m.textInput.Focus()
+		return m, textinput.Blink
+	}
+	usage.question()
+	m.question = question
+	m.thinking = true
+	m.streamed, m.typing, m.revealed, m.streamFrames = "", false, 0, 0
+	m.answeredBy = ""
+	m.footnotes, m.showingNotes = nil, false
+	m.answerPort.GotoTop()
+	m.textInput.Blur()
+	sent := question
+	if m.attachNotes && strings.TrimSpace(m.notes.Value()) != "" {
+		var truncated bool
+		sent, truncated = withNotes(question, m.notes.Value())
+		m.attachNotes = false
+		if truncated {
+			m.notice = fmt.Sprintf("Only part of your notes fit alongside the question (%d characters in all)", maxQuestionRunes)
+			m.footnotes = append(m.footnotes, "only part of your notes were sent")
+		}
+	}
+	// A retry keeps its idempotency key so the backend can answer it only once
+	m.requestID = m.retry.reuse(sent)
+	m.retry = retryKey{}
+	m.sent = sent
+	if m.sessionID != "" {
+		activeSessions.asked(m.sessionID)
+	}
+	// Not the session's context: an answer should outlive a dropped connection
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = withLogger(ctx, m.logger)
+	m.cancelAsk = cancel
+	answerCmd := getAnswerCmd(ctx, sent, m.persona, m.requestID, m.client, m.conversation)
+	if m.pathsWanted {
+		m.pathsWanted = false
+		answerCmd = getPathsCmd(ctx, sent, m.persona, m.requestID, m.client, m.conversation)
+	} else if compareEndpoint != "" {
+		usage.feature("compare")
+		answerCmd = getComparisonCmd(ctx, sent, m.persona, m.requestID)
+	} else if m.session != nil && m.identity != "" {
+		// Only clients with a key can be recognized when they come back
+		answerCmd = deliverIfGone(m.session, m.identity, question, answerCmd)
 	}
+	if m.client != "" {
+		if delay := abuse.observe(m.client, question, time.Now()); delay > 0 {
+			answerCmd = shadowCooldown(delay, answerCmd)
+		}
+	}
+	return m, tea.Batch(
+		tea.Tick(time.Second/10, func(t time.Time) tea.Msg { return spinner.TickMsg{} }),
+		answerCmd,
+	)
+}
+
+// --- View and Rendering Logic ---
 
-	if wisdomResp.Wisdom != "" {
-		return wisdomResp.Wisdom, nil
+func getAnswerCmd(ctx context.Context, question, persona, requestID, asker string, conversation []exchange) tea.Cmd {
+	meta := &answerMeta{}
+	ctx = withAnswerMeta(withRequest(ctx, requestID, persona), meta)
+	ctx = withConversation(withAsker(ctx, asker), conversation)
+	noteQuestion(question, meta)
+	if persona != "" && persona != "orb" {
+		meta.note("answered in the %s persona", persona)
 	}
+	finish := func(answer string, err error) tea.Msg {
+		if ctx.Err() != nil {
+			metricAnswers.Add("interrupted", 1)
+			return interruptedMsg{}
+		}
+		if err != nil {
+			metricAnswers.Add("error", 1)
+			metricLastErrorRequest.Set(requestID)
+			countFailure(err)
+			if !unreachable(err) {
+				return errMsg{err}
+			}
+			loggerFrom(ctx).Error("getting answer, telling a fortune instead", "request", requestID, "err", err)
+			meta.note("no provider could be reached, so a fortune was told")
+			return answerMsg{answer: randomFortune(), expires: time.Now().Add(fortuneTTL), fortune: true, notes: meta.notes}
+		}
+		metricAnswers.Add("ok", 1)
+		metricLastRequest.Set(requestID)
+		answer = cleanAnswer(answer, meta)
+		measureAnswer(answer).record()
+		if meta.expires.IsZero() {
+			meta.expires = nextFullMoon(time.Now())
+		}
+		return answerMsg{answer: answer, expires: meta.expires, provider: meta.provider, notes: meta.notes}
+	}
+	if p, ok := wisdom.(StreamingProvider); ok {
+		return streamAnswerCmd(ctx, p, question, finish)
+	}
+	return func() tea.Msg {
+		return finish(wisdom.GetAnswer(ctx, question))
+	}
+}
 
-	return "", fmt.Errorf("wisdom not found in response")
+// interrupt stops pondering the current question and returns to the input.
+func (m model) interrupt() (tea.Model, tea.Cmd) {
+	m.cancelAsk()
+	m.cancelAsk = nil
+	m.thinking = false
+	m.streamed, m.typing = "", false
+	m.textInput.Reset()
+	m.textInput.Placeholder = "the cosmos was interrupted"
+	m.textInput.Focus()
+	return m, textinput.Blink
 }
 
-func logToFile(text string) {
-	f, err := os.OpenFile("orb_log.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer f.Close()
+// newRequestID returns a random ID identifying one question.
+func newRequestID() string {
+	b := make([]byte, 16)
+	crand.Read(b)
+	return hex.EncodeToString(b)
+}
 
-	if _, err := f.WriteString(text + "\n"); err != nil {
-		log.Fatal(err)
+// retryKey remembers a question that failed, by the exact text sent.
+type retryKey struct {
+	sent      string
+	requestID string
+}
+
+// reuse returns the failed request's ID if sent is the same question again,
+// or a fresh ID otherwise.
+func (r retryKey) reuse(sent string) string {
+	if r.requestID != "" && r.sent == sent {
+		return r.requestID
 	}
+	return newRequestID()
 }
 
 // Deepest, almost black tone for the rim
@@ -292,7 +1057,11 @@ func applyGradient(text string, palette []lipgloss.Color, frame int, newStyle fu
 	return builder.String()
 }
 
-func renderOrbPixel(x, y, orbWidth, orbHeight, radius, frame int, palette []lipgloss.Color, newStyle func() lipgloss.Style) string {
+// orbPixelColor returns the color of the orb at a cell, and false if the
+// cell lies outside the orb.
+// A ring between 0 and 1 lights that fraction of the rim clockwise from the
+// top; a negative ring leaves the rim alone.
+func orbPixelColor(x, y, orbWidth, orbHeight, radius, frame int, palette []lipgloss.Color, rim lipgloss.Color, ring rimRing) (lipgloss.Color, bool) {
 	nx := float64(x) - float64(orbWidth)/2.0
 	ny := float64(y) - float64(orbHeight)/2.0
 
@@ -303,11 +1072,20 @@ func renderOrbPixel(x, y, orbWidth, orbHeight, radius, frame int, palette []lipg
 		swirlValue := (dist * 0.2) + math.Sin(nx/6.0+ny/8.0+float64(frame)/10.0) + math.Cos(ny/10.0+nx/12.0+float64(frame)/15.0)
 		color := getColorSubtle(swirlValue, palette)
 		if dist > float64(radius)*0.9 {
-			color = darkestBlue
+			color = rim
+			if ring.lit >= 0 {
+				angle := math.Atan2(nx/2.0, -ny)
+				if angle < 0 {
+					angle += 2 * math.Pi
+				}
+				if angle/(2*math.Pi) < ring.lit {
+					color = ring.color
+				}
+			}
 		}
-		return newStyle().Foreground(color).SetString("█").String()
+		return color, true
 	}
-	return " "
+	return "", false
 }
 
 func (m model) View() string {
@@ -315,61 +1093,152 @@ func (m model) View() string {
 	if m.renderer != nil {
 		newStyle = m.renderer.NewStyle
 	}
-	termWidth := m.width
-	if termWidth == 0 {
-		termWidth = 60
-	} else if termWidth > 100 {
-		termWidth = 98
-	}
-
 	// Orb dimensions
-	orbWidth := termWidth
-	radius := orbWidth / 4
-	orbHeight := radius * 2
-	visibleOrbHeight := int(float64(orbHeight) * 0.6)
+	l := computeLayout(m.width, m.quick || m.inline, multiOrb)
+	termWidth := l.width
+	orbWidth := l.orbWidth
+	radius := l.radius
+	orbHeight := l.orbHeight
+	visibleOrbHeight := l.visibleHeight
+	ring := m.ring(time.Now())
+	if m.meditation != nil {
+		// Pulse with the breath; the layout keeps the full-size geometry
+		radius = int(float64(radius) * m.meditation.scale(time.Now()))
+	}
 
 	// Palette
-	baseHue := math.Mod(float64(m.frame)/3.0, 360)
-	palette := make([]lipgloss.Color, 5)
-	for i := 0; i < 5; i++ {
-		hue := baseHue + float64(i)*15
-		sat := 65.0 + float64(i)*3
-		light := 65.0 - float64(i)*2
-		palette[i] = lipgloss.Color(hslToHex(hue, sat, light))
-	}
+	frame := m.theme.swirlFrame(m.frame)
+	colors := m.colors(time.Now())
+	palette, rim := colors.palette, colors.rim
+	m.cells.begin(m.colorProfile(), newStyle)
+	orb := orbFrame{orb: mainOrb, width: orbWidth, height: orbHeight, radius: radius, frame: frame, palette: palette, rim: rim, ring: ring}
 
 	// Header setup
-	gradientPalette := make([]lipgloss.Color, 10)
-	for i := 0; i < 10; i++ {
-		hue := baseHue + float64(i)*10
-		sat := 70.0
-		light := 65.0
-		gradientPalette[i] = lipgloss.Color(hslToHex(hue, sat, light))
-	}
+	gradientPalette := colors.header
 	headerLines := strings.Split(header, "\n")
 	var styledHeaderLines []string
 	for _, line := range headerLines {
-		styledHeaderLines = append(styledHeaderLines, applyGradient(line, gradientPalette, m.frame, newStyle))
+		styledHeaderLines = append(styledHeaderLines, applyGradient(line, gradientPalette, ambientFrame(m.frame), newStyle))
 	}
 	headerView := lipgloss.JoinVertical(lipgloss.Left, styledHeaderLines...)
 	headerView = newStyle().Width(termWidth).Align(lipgloss.Center).Render(headerView)
+	if pin, ok := pinned.current(time.Now()); ok {
+		pinView := newStyle().Width(termWidth).Align(lipgloss.Center).Italic(true).Foreground(lipgloss.Color("245")).
+			Render(truncateRunes("✧ "+strings.Join(strings.Fields(pin.Answer), " ")+" ✧", orbWidth))
+		headerView = lipgloss.JoinVertical(lipgloss.Left, headerView, pinView)
+	}
+	if m.banner != "" {
+		return lipgloss.JoinVertical(lipgloss.Left, headerView, renderBanner(m.banner, gradientPalette, ambientFrame(m.frame), termWidth, visibleOrbHeight, newStyle))
+	}
 
-	// Interactive element setup
+	// Interactive element setup, as wide as the answer box at most
+	boxWidth := orbWidth / 2
 	var interactiveElement string
-	if m.thinking {
-		spinnerView := m.spinner.View() + " consulting the cosmos..."
-		interactiveElement = newStyle().Padding(1, 2).Render(spinnerView)
+	if m.thinking && m.typing {
+		// The answer is arriving; the spinner fades out above it
+		answerView := newStyle().Padding(1, 2).Render(m.answerBody(newStyle))
+		if color, ok := spinnerFaded(m.streamFrames); ok && !m.still {
+			m.spinner.Style = m.spinner.Style.Foreground(color)
+			answerView = lipgloss.JoinVertical(lipgloss.Center, m.spinner.View(), answerView)
+		}
+		interactiveElement = answerView
+	} else if m.thinking {
+		spinnerView := m.spinner.View() + " " + flavorFor(m.persona).thinking
+		interactiveElement = fitBox(newStyle().Padding(1, 2), spinnerView, boxWidth)
+	} else if m.focus != nil {
+		interactiveElement = newStyle().Padding(1, 2).Foreground(lipgloss.Color("240")).Render(m.focus.status(time.Now()))
+	} else if m.themeEditor != nil {
+		interactiveElement = newStyle().Padding(1, 2).Background(lipgloss.Color("#222")).Render(m.themeEditor.status(m.theme))
+	} else if m.history != nil {
+		interactiveElement = newStyle().Padding(1, 2).Background(lipgloss.Color("#222")).Render(m.history.status(orbWidth/2, m.answerLayout, newStyle))
+	} else if m.meditation != nil {
+		interactiveElement = newStyle().Padding(1, 2).Align(lipgloss.Center).Render(m.meditation.status(time.Now()))
+	} else if m.paths != nil {
+		interactiveElement = renderPaths(m.paths, orbWidth, newStyle)
+	} else if m.listening {
+		spinnerView := m.spinner.View() + " listening..."
+		interactiveElement = fitBox(newStyle().Padding(1, 2), spinnerView, boxWidth)
+	} else if m.showingAnswer && m.comparing {
+		interactiveElement = renderComparison(m.comparison, m.preferred, orbWidth, newStyle)
 	} else if m.showingAnswer {
-		answerView := newStyle().Padding(1, 2).Render(m.answer)
-		promptView := newStyle().Padding(0, 2).Foreground(lipgloss.Color("240")).Render("Ask another question [enter]")
+		answerView := newStyle().Padding(1, 2).Render(m.answerBody(newStyle))
+		if m.showingDiff {
+			answerView = newStyle().Padding(1, 2).Render(renderDiff(m.previous, m.answer, orbWidth/2, newStyle))
+		}
+		if m.seal == "" && m.requestID != "" {
+			// Error details: enough for an operator to find this question in the logs
+			idView := fitBox(newStyle().Foreground(lipgloss.Color("240")), "request "+m.requestID, boxWidth)
+			answerView = lipgloss.JoinVertical(lipgloss.Center, answerView, idView)
+		}
+		if m.notice != "" {
+			noticeView := fitBox(newStyle().Padding(1, 2, 0).Foreground(lipgloss.Color("240")), m.notice, boxWidth)
+			answerView = lipgloss.JoinVertical(lipgloss.Center, noticeView, answerView)
+		}
+		if m.answeredBy != "" {
+			byView := fitBox(newStyle().Foreground(lipgloss.Color("238")), "answered by "+m.answeredBy, boxWidth)
+			answerView = lipgloss.JoinVertical(lipgloss.Center, answerView, byView)
+		}
+		if m.showingNotes {
+			answerView = newStyle().Padding(1, 2).Width(boxWidth).Background(lipgloss.Color("#222")).Render(footnotes(m.footnotes))
+		} else if len(m.footnotes) > 0 {
+			noteView := fitBox(newStyle().Foreground(lipgloss.Color("238")), fmt.Sprintf("† %d [f]", len(m.footnotes)), boxWidth)
+			answerView = lipgloss.JoinVertical(lipgloss.Center, answerView, noteView)
+		}
+		if m.stats.runes > longAnswerRunes {
+			hintView := fitBox(newStyle().Foreground(lipgloss.Color("240")), m.stats.readingHint(), boxWidth)
+			answerView = lipgloss.JoinVertical(lipgloss.Center, answerView, hintView)
+		}
+		if m.seal != "" {
+			seal := m.seal
+			if m.signature != "" {
+				// The full signature, so the card can be copied and verified
+				seal += "\n✓ signed " + m.signature
+			}
+			sealView := fitBox(newStyle().Foreground(lipgloss.Color("240")).Align(lipgloss.Center), seal, boxWidth)
+			answerView = lipgloss.JoinVertical(lipgloss.Center, answerView, sealView)
+		}
+		promptText := "Ask another question [enter]"
+		if m.quick {
+			promptText = "Close [enter]"
+		}
+		if m.previous != "" {
+			promptText = "the prophecy has changed [d] · " + promptText
+		}
+		if m.stale() {
+			promptText = "this prophecy has grown stale, re-ask [r] · " + promptText
+		}
+		if m.session == nil && utf8.RuneCountInString(m.answer) > longAnswerRunes {
+			promptText += " · read in pager [p]"
+		}
+		if m.recorder != nil {
+			promptText += " · save clip [c]"
+		}
+		if m.session == nil && todoPath != "" && m.seal != "" {
+			promptText += " · add to tasks [t]"
+		}
+		if m.parting {
+			promptText = "Farewell, seeker"
+		}
+		promptView := fitBox(newStyle().Padding(0, 2).Foreground(lipgloss.Color("240")), promptText, boxWidth)
 		interactiveElement = lipgloss.JoinVertical(lipgloss.Center, answerView, promptView)
 	} else {
 		m.textInput.Width = orbWidth / 2
-		prompt := newStyle().Padding(0, 1).Foreground(lipgloss.Color("#FFF")).Render("What is the knowledge you seek?")
-		inputBox := newStyle().Padding(1, 3).Background(lipgloss.Color("#222")).Render(m.textInput.View())
+		prompt := fitBox(newStyle().Padding(0, 1).Foreground(lipgloss.Color("#FFF")), flavorFor(m.persona).prompt, boxWidth)
+		inputBox := newStyle().Padding(1, 3).Background(lipgloss.Color("#222")).Render(m.inputView())
 		interactiveElement = lipgloss.JoinVertical(lipgloss.Center, prompt, inputBox)
+		if expanded, ok := expandMacro(m.textInput.Value(), m.macros); ok {
+			previewView := fitBox(newStyle().Padding(0, 1).Foreground(lipgloss.Color("240")), "→ "+expanded, boxWidth)
+			interactiveElement = lipgloss.JoinVertical(lipgloss.Center, interactiveElement, previewView)
+		}
 	}
 
+	if m.hidden {
+		interactiveElement = obscure(interactiveElement, newStyle)
+	}
+	if lipgloss.Width(interactiveElement) > orbWidth-2 {
+		// Never let the box outgrow the orb, whatever was put in it
+		interactiveElement = newStyle().MaxWidth(orbWidth - 2).Render(interactiveElement)
+	}
 	textBoxWidth := lipgloss.Width(interactiveElement)
 	textBoxHeight := lipgloss.Height(interactiveElement)
 	textBoxStartX := orbWidth/2 - textBoxWidth/2
@@ -382,31 +1251,81 @@ func (m model) View() string {
 		isTextBoxLine := y >= textBoxStartY && y < textBoxStartY+textBoxHeight
 
 		if isTextBoxLine {
-			leftOrb := ""
-			for x := 0; x < textBoxStartX; x++ {
-				leftOrb += renderOrbPixel(x, y, orbWidth, orbHeight, radius, m.frame, palette, newStyle)
-			}
+			leftOrb := m.cells.span(orb, y, 0, textBoxStartX)
 			textBoxLine := textBoxLines[y-textBoxStartY]
-			rightOrb := ""
-			for x := textBoxStartX + textBoxWidth; x < orbWidth; x++ {
-				rightOrb += renderOrbPixel(x, y, orbWidth, orbHeight, radius, m.frame, palette, newStyle)
-			}
+			rightOrb := m.cells.span(orb, y, textBoxStartX+textBoxWidth, orbWidth)
 			lines = append(lines, lipgloss.JoinHorizontal(lipgloss.Top, leftOrb, textBoxLine, rightOrb))
 		} else {
-			line := ""
-			for x := 0; x < orbWidth; x++ {
-				line += renderOrbPixel(x, y, orbWidth, orbHeight, radius, m.frame, palette, newStyle)
-			}
-			lines = append(lines, line)
+			lines = append(lines, m.cells.span(orb, y, 0, orbWidth))
 		}
 	}
 	ball := lipgloss.JoinVertical(lipgloss.Left, lines...)
+	if l.satelliteWidth > 0 {
+		left := renderSatellite(l, m.cells, orbFrame{orb: leftSatellite, frame: frame + 360, palette: palette, rim: rim})
+		right := renderSatellite(l, m.cells, orbFrame{orb: rightSatellite, frame: frame + 720, palette: palette, rim: rim})
+		ball = lipgloss.JoinHorizontal(lipgloss.Top, left, ball, right)
+	}
+
+	// Scratchpad
+	if m.notesOpen {
+		m.notes.SetWidth(orbWidth - 4)
+		attach := "off"
+		if m.attachNotes {
+			attach = "on"
+		}
+		label := newStyle().Foreground(lipgloss.Color("240")).Render(
+			"notes · attach to next question: " + attach + " [ctrl+t] · close [ctrl+n]")
+		notesText := m.notes.View()
+		if m.hidden {
+			notesText = obscure(notesText, newStyle)
+		}
+		notesView := newStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("240")).Render(notesText)
+		ball = lipgloss.JoinVertical(lipgloss.Left, ball, notesView, label)
+	}
 
 	// Instructions
-	instructions := newStyle().Foreground(lipgloss.Color("#626262")).Render("\nPress Ctrl+C to quit.")
+	help := "\nPress Ctrl+C to quit. Ctrl+R lets the orb choose your question. Ctrl+N opens your notes, Ctrl+O your history, Ctrl+T the next theme; ↑ brings back earlier questions. Ctrl+X hides the answer from onlookers, Ctrl+S stills the orb."
+	switch n := len(m.conversation); {
+	case n == 1:
+		help += " The orb remembers your last question; Ctrl+L starts afresh."
+	case n > 1:
+		help += fmt.Sprintf(" The orb remembers your last %d questions; Ctrl+L starts afresh.", n)
+	}
+	if m.thinking {
+		help += " Esc interrupts the orb."
+	}
+	help += " " + m.privacyNotice()
+	if m.hidden {
+		help = "\nHidden from onlookers; Ctrl+X shows it again."
+	}
+	if telemetryEndpoint != "" {
+		help += " Anonymous usage counts, never questions, are reported to help improve the orb."
+	}
+	if m.handoffCode != "" {
+		help += " To continue on another device, enter " + handoffCommand + " " + m.handoffCode + " there."
+	}
+	instructions := newStyle().Foreground(lipgloss.Color("#626262")).Render(help)
+	if warning := m.lifetimeWarningText(time.Now()); warning != "" {
+		instructions = lipgloss.JoinVertical(lipgloss.Left, instructions, newStyle().Foreground(lipgloss.Color(cooldownRingColor)).Render(warning))
+	}
+	if warning := m.idleWarningText(time.Now()); warning != "" {
+		instructions = lipgloss.JoinVertical(lipgloss.Left, instructions, newStyle().Foreground(lipgloss.Color(cooldownRingColor)).Render(warning))
+	}
 
 	// Final layout
-	return lipgloss.JoinVertical(lipgloss.Left, headerView, ball, instructions)
+	var view string
+	switch {
+	case m.quick:
+		view = ball
+	case m.inline:
+		view = lipgloss.JoinVertical(lipgloss.Left, ball, instructions)
+	default:
+		view = lipgloss.JoinVertical(lipgloss.Left, headerView, ball, instructions)
+	}
+	if m.recorder != nil {
+		m.recorder.add(time.Now(), view)
+	}
+	return view
 }
 
 func teaHandler(s ssh.Session) (tea.Model, []tea.ProgramOption) {
@@ -416,43 +1335,404 @@ func teaHandler(s ssh.Session) (tea.Model, []tea.ProgramOption) {
 		return nil, nil
 	}
 	renderer := bubbletea.MakeRenderer(s)
-	renderer.SetColorProfile(termenv.TrueColor)
+	usage.session()
 	m := initialModel()
-	m.width = pty.Window.Width
-	m.height = pty.Window.Height
-	m.renderer = renderer
-	m.textInput.TextStyle = renderer.NewStyle().Foreground(lipgloss.Color("#FFF")).Background(lipgloss.Color("#222"))
-	m.spinner.Style = renderer.NewStyle().Foreground(lipgloss.Color("155"))
+	m.width, m.height = clampSize(pty.Window.Width, pty.Window.Height)
+	m.useRenderer(renderer)
+	m.env = sessionEnv(s.Environ(), acceptedEnv)
+	renderer.SetColorProfile(sessionProfile(renderer.ColorProfile(), m.env))
+	m.applyPreferences(m.env)
+	m.user = s.User()
+	m.identity = keyFingerprint(s)
+	m.client = clientID(s)
+	m.addr = remoteIP(s)
+	if blockedKeys[m.client] {
+		abuse.flagBlockedKey(m.client)
+	}
+	m.session = s.Context()
+	m.sessionID = s.Context().SessionID()
+	m.logger = sessionLogger(s)
+	m.showBanner()
+	if notes, err := loadNotes(m.identity); err != nil {
+		m.logger.Error("loading notes", "err", err)
+	} else {
+		m.notes.SetValue(notes)
+	}
+	if m.identity != "" {
+		conversation, returning, err := resumeConversation(m.identity, time.Now())
+		if err != nil {
+			m.logger.Error("resuming conversation", "err", err)
+		}
+		if returning {
+			m.conversation = conversation
+			m.textInput.Placeholder = "welcome back, " + displayName(m.identity)
+		}
+	}
+	if p, ok := pendingAnswers.take(m.identity); ok {
+		m.question = p.question
+		m.answer = p.answer
+		m.expires = p.expires
+		m.seal = prophecySeal(p.question, p.answer)
+		m.signature = signAnswer(p.question, p.answer)
+		m.stats = measureAnswer(p.answer)
+		m.notice = "While you were away, the orb finished pondering \"" + p.question + "\""
+		m.showingAnswer = true
+		m.textInput.Blur()
+	}
 	return m, []tea.ProgramOption{tea.WithAltScreen()}
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "quick":
+			loadSharedConfig()
+			runQuick()
+			return
+		case "loadtest":
+			runLoadtest(os.Args[2:])
+			return
+		case "mockserver":
+			runMockserver(os.Args[2:])
+			return
+		case "verify":
+			runVerify(os.Args[2:])
+			return
+		case "ask":
+			loadSharedConfig()
+			runAsk(os.Args[2:])
+			return
+		case "batch":
+			loadSharedConfig()
+			runBatch(os.Args[2:])
+			return
+		case "restore":
+			loadSharedConfig()
+			runRestore(os.Args[2:])
+			return
+		case "pin":
+			loadSharedConfig()
+			runPin(os.Args[2:])
+			return
+		}
+	}
+
+	configFlag := flag.String("config", "", "config file to read (default $XDG_CONFIG_HOME/orb/config.toml)")
 	sshFlag := flag.Bool("ssh", false, "run as ssh server")
+	sshAddressFlag := flag.String("ssh-address", ":2222", "address the ssh server listens on")
+	flag.StringVar(sshAddressFlag, "listen", *sshAddressFlag, "address the ssh server listens on, as host:port (same as --ssh-address; ORB_LISTEN)")
+	sshHostKeyFlag := flag.String("ssh-host-key", ".ssh/orb_host_key", "host key of the ssh server, generated if missing")
+	flag.StringVar(sshHostKeyFlag, "host-key", *sshHostKeyFlag, "host key of the ssh server, e.g. /etc/orb/ssh_host_ed25519_key (same as --ssh-host-key; ORB_HOST_KEY)")
+	endpointFlag := flag.String("endpoint", wisdomEndpoint, "wisdom API the ponder provider asks; ORB_ENDPOINT overrides the config file")
+	historyFlag := flag.String("history", historyPath, "JSONL file questions and answers are recorded in")
+	conversationTurnsFlag := flag.Int("conversation-turns", conversationTurns, "earlier questions and answers sent along so follow-ups make sense (0 to ask each afresh)")
+	recallHistoryFlag := flag.Bool("recall-history", recallHistory, "let the up arrow recall questions from earlier visits, not just this one")
+	noLogFlag := flag.Bool("no-log", false, "never record questions in the history; askers are told either way")
+	historyMaxSizeFlag := flag.Int("history-max-size", int(historyMaxSize>>20), "rotate the history into a gzipped archive once it grows past this many MB, put back with orb restore (0 disables)")
+	archiveAfterFlag := flag.Int("archive-after", 0, "move history older than this many days into gzipped archives, put back with orb restore (0 disables)")
+	themeFlag := flag.String("theme", orbTheme, "theme sessions start with, unless ORB_THEME picks another: cosmic, fire, sea, emerald, ember, monochrome, colorblind or a saved one")
+	animationFPSFlag := flag.Int("animation-fps", animationFPS, "frames per second the orb is animated at, at most; ssh sessions drop below it while their link can't keep up")
+	flag.IntVar(animationFPSFlag, "max-fps", *animationFPSFlag, "most frames per second the orb is animated at (same as --animation-fps)")
+	colorsFlag := flag.String("colors", sessionColors, "colors ssh sessions are drawn with: auto from the client's TERM and COLORTERM, truecolor, 256, 16 or none; clients can pick with ORB_COLORS")
+	noAnimationFlag := flag.Bool("no-animation", false, "start sessions with a still orb that's only redrawn when something changes; Ctrl+S toggles it")
+	lowPowerFlag := flag.Bool("low-power", false, "save energy with fewer frames, no ambient effects and duller colors (default on battery in local mode)")
+	terminalTitleFlag := flag.Bool("terminal-title", terminalTitles, "announce the orb's state in the terminal title")
+	inlineFlag := flag.Bool("inline", false, "render a small orb inline instead of using the alt screen")
+	acceptEnvFlag := flag.String("accept-env", strings.Join(defaultAcceptedEnv, ","), "comma separated client env vars accepted in ssh sessions")
+	metricsAddrFlag := flag.String("metrics-addr", "", "serve self-check metrics on this address (e.g. :9090)")
+	selfcheckFlag := flag.Duration("selfcheck-interval", time.Minute, "how often to log resource self-checks in ssh mode (0 disables)")
+	focusStatsFlag := flag.String("focus-stats", focusStatsPath, "file where /focus session stats are stored per identity (default beside the history)")
+	aphorismFlag := flag.Bool("meditation-aphorism", true, "close /breathe sessions with an aphorism")
+	clipSecondsFlag := flag.Int("clip-seconds", clipSeconds, "seconds of the orb kept in local mode to save as an asciinema clip with [c] (0 disables)")
+	todoFlag := flag.String("todo-file", "", "file answers are added to as tasks with [t] in local mode; .md gets Markdown checkboxes, anything else todo.txt lines")
+	speechFlag := flag.String("speech-command", "", "speech-to-text command used by /speak in local mode")
+	allowHostsFlag := flag.String("allow-hosts", "", "comma separated extra hosts outbound requests may reach")
+	requestTimeoutFlag := flag.Duration("request-timeout", outboundClient.Timeout, "how long one attempt at asking a provider may take")
+	retryAttemptsFlag := flag.Int("retry-attempts", retryAttempts, "how often to try a provider that can't be reached or is overloaded")
+	allowPrivateFlag := flag.Bool("allow-private-addrs", false, "let outbound requests reach loopback and private addresses")
+	promptTextFlag := flag.String("prompt-text", deploymentFlavor.prompt, "heading shown above the question input")
+	placeholderFlag := flag.String("placeholder", deploymentFlavor.placeholder, "placeholder shown in the empty question input")
+	thinkingTextFlag := flag.String("thinking-text", deploymentFlavor.thinking, "message shown while waiting for an answer")
+	errorTextFlag := flag.String("error-text", deploymentFlavor.silence, "message shown when no answer could be had")
+	providerFlag := flag.String("provider", defaultProvider, "wisdom provider to consult, or a comma separated list to fall back through in order ("+strings.Join(providerNames(), ", ")+")")
+	maxConcurrentFlag := flag.String("max-concurrent", "", "questions each provider works on at once, as N or name=N,... (default unlimited)")
+	breakerFailuresFlag := flag.Int("breaker-failures", breakerFailures, "failures within --breaker-window that take a provider out of the fallback chain")
+	breakerWindowFlag := flag.Duration("breaker-window", breakerWindow, "window failures are counted in, and how long a failing provider is skipped")
+	ollamaURLFlag := flag.String("ollama-url", ollamaURL, "base URL of the Ollama instance for --provider ollama")
+	ollamaModelFlag := flag.String("ollama-model", ollamaModel, "model Ollama should answer with")
+	openaiURLFlag := flag.String("openai-url", openaiURL, "base URL of the OpenAI-compatible API for --provider openai")
+	openaiModelFlag := flag.String("openai-model", openaiModel, "model the OpenAI-compatible API should answer with")
+	openaiTemperatureFlag := flag.Float64("openai-temperature", openaiTemperature, "sampling temperature for --provider openai")
+	openaiKeyFileFlag := flag.String("openai-key-file", "", "file holding the API key for --provider openai (default $OPENAI_API_KEY)")
+	openaiSystemPromptFlag := flag.String("openai-system-prompt", "", "system prompt for --provider openai (default the persona's prompt)")
+	offlineFlag := flag.Bool("offline", false, "tell fortunes from the bundled database instead of consulting a provider")
+	fortunesFlag := flag.String("fortunes", "", "file of extra fortunes, one per line, for offline answers")
+	routesFlag := flag.String("routes", "", "JSON file of routing rules sending questions to providers and personas by topic")
+	rulesFlag := flag.String("rules", "", "JSON file of canned answers checked before the provider, reloaded on change")
+	compareFlag := flag.String("compare", "", "also ask this wisdom endpoint and show both answers side by side")
+	themesDirFlag := flag.String("themes-dir", "", "load theme files from and save /theme edits to this directory")
+	notesDirFlag := flag.String("notes-dir", "", "persist scratchpad notes per user in this directory")
+	multiOrbFlag := flag.Bool("multi-orb", false, "draw decorative side orbs on very wide terminals")
+	webFlag := flag.String("web", "", "in ssh mode, also serve the orb to browsers on this address (e.g. :8080)")
+	webAssetsFlag := flag.String("web-assets", "", "directory holding the xterm and addon-fit packages for --web (default the copy built in from web/xterm)")
+	overlayFlag := flag.String("overlay", "", "serve a stream overlay of the orb on this address (e.g. 127.0.0.1:8765; a bare :port binds loopback)")
+	signingKeyFlag := flag.String("signing-key", "", "sign answers with this ed25519 key, generated if missing; checked at /verify on --metrics-addr")
+	maxSessionsFlag := flag.Int("max-sessions", 0, "ssh sessions served at once; more are told to come back later (0 for no limit)")
+	autoTuneFlag := flag.Bool("auto-tune", false, "in ssh mode, set --animation-fps and --max-sessions from a startup benchmark unless they're given")
+	shutdownTimeoutFlag := flag.Duration("shutdown-timeout", shutdownTimeout, "how long open ssh sessions get to close on SIGINT, SIGTERM or a restart")
+	idleTimeoutFlag := flag.Duration("idle-timeout", idleTimeout, "how long an ssh or web session may go without a key press before it's disconnected (0 to never)")
+	maxSessionLifetimeFlag := flag.Duration("max-session-lifetime", maxSessionLifetime, "how long an ssh or web session may last in all before a parting prophecy closes it (0 for no limit)")
+	connRateFlag := flag.Float64("conn-rate", 0, "new ssh connections a minute allowed from one IP address (0 disables)")
+	connBurstFlag := flag.Int("conn-burst", 10, "connections one IP address may open at once before --conn-rate applies")
+	questionRateFlag := flag.Float64("question-rate", 0, "questions a minute allowed from one IP address (0 disables)")
+	questionBurstFlag := flag.Int("question-burst", 5, "questions one IP address may ask at once before --question-rate applies")
+	aliasesFlag := flag.String("aliases", "", "authorized_keys file naming known clients in each key's comment, to greet them by")
+	adminKeysFlag := flag.String("admin-keys", "", "authorized_keys file of admins who may open the session dashboard with ssh -t host admin")
+	blockedKeysFlag := flag.String("blocked-keys", "", "authorized_keys file of abusive clients whose questions are slowed down")
+	telemetryFlag := flag.String("telemetry", "", "opt in to reporting anonymous usage counts (version, sessions, features used; never questions) to this URL")
+	noTelemetryFlag := flag.Bool("no-telemetry", false, "never report usage counts, whatever --telemetry or the config file say")
+	easingFlag := flag.String("easing", "smooth", "curve the orb's effects are timed with: smooth, spring or linear")
+	bannerFlag := flag.String("banner", "", "text/template shown to ssh and web sessions before the orb, e.g. \"Welcome to {{.Server}}\" ({{.User}}, {{.Questions}} and {{.Sessions}} too)")
+	bannerFileFlag := flag.String("banner-file", "", "file holding the banner template, instead of --banner")
+	serverNameFlag := flag.String("server-name", "", "name the banner calls the server by (default the host name)")
+	maxGoroutinesFlag := flag.Int("max-goroutines", 0, "restart the ssh server when this many goroutines are running (0 disables)")
+	logLevelFlag := flag.String("log-level", "info", "least severe log entries written: debug, info, warn or error")
+	logFormatFlag := flag.String("log-format", "text", "how log entries are written: text or json")
 	flag.Parse()
+	configPath := *configFlag
+	if configPath == "" {
+		configPath = defaultConfigPath()
+	}
+	if flag.Arg(0) == "doctor" {
+		runDoctor(flag.Args()[1:], configPath, *configFlag != "")
+		return
+	}
+	if err := applyEnv(flag.CommandLine); err != nil {
+		fatal(err)
+	}
+	config, err := readConfig(configPath, *configFlag != "")
+	if err != nil {
+		fatal(err)
+	}
+	configMacros = takeMacros(config)
+	if err := applyConfig(flag.CommandLine, configPath, config); err != nil {
+		fatal(err)
+	}
+	if err := setupLogging(*logLevelFlag, *logFormatFlag); err != nil {
+		fatal(err)
+	}
+	wisdomEndpoint = *endpointFlag
+	historyPath = *historyFlag
+	recallHistory = *recallHistoryFlag
+	conversationTurns = min(*conversationTurnsFlag, maxConversationTurns)
+	historyMaxSize = int64(*historyMaxSizeFlag) << 20
+	noLog = *noLogFlag
+	archiveAge := time.Duration(*archiveAfterFlag) * 24 * time.Hour
+	archiveOldHistory(archiveAge)
+	if err := pinned.reload(); err != nil {
+		slog.Error("loading pin", "err", err)
+	}
+	go pinned.watch(pinReloadInterval)
+	orbTheme = *themeFlag
+	if *animationFPSFlag > 0 {
+		animationFPS = *animationFPSFlag
+	}
+	if err := parseColors(*colorsFlag); err != nil {
+		fatal(err)
+	}
+	sessionColors = *colorsFlag
+	noAnimation = *noAnimationFlag
+	lowPower = *lowPowerFlag
+	if !*sshFlag && !explicitFlags(flag.CommandLine)["low-power"] && onBattery() {
+		lowPower = true
+		slog.Info("on battery, so saving power (--low-power=false turns this off)")
+	}
+
+	terminalTitles = *terminalTitleFlag
+	clipSeconds = *clipSecondsFlag
+	if effectEasing, err = parseEasing(*easingFlag); err != nil {
+		fatal(err)
+	}
+	todoPath = *todoFlag
+	if bannerTemplate, err = loadBanner(*bannerFlag, *bannerFileFlag); err != nil {
+		fatal(err)
+	}
+	serverName = *serverNameFlag
+	if serverName == "" {
+		serverName, _ = os.Hostname()
+	}
+	acceptedEnv = parseList(*acceptEnvFlag)
+	showAphorism = *aphorismFlag
+	focusStatsPath = *focusStatsFlag
+	multiOrb = *multiOrbFlag
+	notesDir = *notesDirFlag
+	themesDir = *themesDirFlag
+	if themesDir != "" {
+		if err := loadThemes(themesDir); err != nil {
+			fatal(fmt.Errorf("failed to load themes: %w", err))
+		}
+	}
+	compareEndpoint = *compareFlag
+	deploymentFlavor = flavor{
+		prompt:      *promptTextFlag,
+		placeholder: *placeholderFlag,
+		thinking:    *thinkingTextFlag,
+		silence:     *errorTextFlag,
+	}
+	if telemetryEndpoint = *telemetryFlag; *noTelemetryFlag {
+		telemetryEndpoint = ""
+	}
+	allowEndpointHosts(wisdomEndpoint, compareEndpoint, telemetryEndpoint)
+	for _, host := range parseList(*allowHostsFlag) {
+		allowedHosts[strings.ToLower(host)] = true
+	}
+	allowPrivateAddrs = *allowPrivateFlag
+	outboundClient.Timeout = *requestTimeoutFlag
+	retryAttempts = max(*retryAttemptsFlag, 1)
+	ollamaURL, ollamaModel = *ollamaURLFlag, *ollamaModelFlag
+	openaiURL, openaiModel, openaiTemperature = *openaiURLFlag, *openaiModelFlag, *openaiTemperatureFlag
+	openaiKeyFile, openaiSystemPrompt = *openaiKeyFileFlag, *openaiSystemPromptFlag
+	breakerFailures, breakerWindow = max(*breakerFailuresFlag, 1), *breakerWindowFlag
+	maxSessions = *maxSessionsFlag
+	autoTune = *autoTuneFlag
+	idleTimeout = *idleTimeoutFlag
+	maxSessionLifetime = *maxSessionLifetimeFlag
+	shutdownTimeout = *shutdownTimeoutFlag
+	connLimiter = newIPLimiter(*connRateFlag, *connBurstFlag)
+	questionLimiter = newIPLimiter(*questionRateFlag, *questionBurstFlag)
+	if providerLimits, err = parseProviderLimits(*maxConcurrentFlag); err != nil {
+		fatal(err)
+	}
+	if err := setupWisdom(*providerFlag, *offlineFlag, *fortunesFlag, *routesFlag, *rulesFlag); err != nil {
+		fatal(err)
+	}
+	if *signingKeyFlag != "" {
+		key, err := loadSigningKey(*signingKeyFlag)
+		if err != nil {
+			fatal(err)
+		}
+		answerSigner = key
+		// Served alongside the metrics on --metrics-addr
+		http.HandleFunc("/verify", handleVerify)
+	}
 
 	rand.Seed(time.Now().UnixNano())
+	if telemetryEndpoint != "" {
+		slog.Info("telemetry: reporting anonymous usage counts (--no-telemetry turns this off)", "endpoint", telemetryEndpoint, "interval", telemetryInterval)
+		go runTelemetry()
+	}
 
 	if *sshFlag {
-		s, err := wish.NewServer(
-			wish.WithAddress(":2222"),
-			wish.WithHostKeyPath(".ssh/orb_host_key"),
+		tuneCapacity(explicitFlags(flag.CommandLine))
+		hostKey, err := withHostKey(*sshHostKeyFlag)
+		if err != nil {
+			fatal(err)
+		}
+		opts := []ssh.Option{
+			wish.WithAddress(*sshAddressFlag),
+			hostKey,
+			withConnCounting(),
+			wish.WithSubsystem("sftp", transcriptSFTP),
 			wish.WithMiddleware(
-				bubbletea.Middleware(teaHandler),
-				logging.Middleware(),
+				orbSessions(),
+				lineMode(),
+				adminMode(),
+				transcriptDelivery(),
+				limitPerIP(),
+				trackSessions(),
+				logSessions(),
 			),
-		)
+		}
+		// Keys let clients be recognized when they reconnect
+		opts = append(opts, acceptAnyKey()...)
+		if *blockedKeysFlag != "" {
+			if err := loadBlockedKeys(*blockedKeysFlag); err != nil {
+				fatal(err)
+			}
+		}
+		if *aliasesFlag != "" {
+			if err := loadAliases(*aliasesFlag); err != nil {
+				fatal(err)
+			}
+		}
+		if *adminKeysFlag != "" {
+			if err := loadAdminKeys(*adminKeysFlag); err != nil {
+				fatal(err)
+			}
+		}
+		s, err := wish.NewServer(opts...)
 		if err != nil {
-			log.Fatalln(err)
+			fatal(err)
+		}
+
+		if *metricsAddrFlag != "" {
+			go func() {
+				if err := http.ListenAndServe(*metricsAddrFlag, nil); err != nil {
+					slog.Error("metrics server stopped", "err", err)
+				}
+			}()
+		}
+		if *webFlag != "" {
+			webAssetsDir = *webAssetsFlag
+			go serveWebTerminal(*webFlag)
+		}
+		go runArchiver(archiveAge)
+		stopped := make(chan struct{})
+		var stopOnce sync.Once
+		stop := func() {
+			// Let open sessions close, then exit; a supervisor restarts us
+			stopOnce.Do(func() {
+				shutdownServer(s)
+				close(stopped)
+			})
 		}
+		go runSelfChecks(*selfcheckFlag, *maxGoroutinesFlag, stop)
+		signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals.Done()
+			stopSignals() // A second signal kills the server outright
+			stop()
+		}()
 
-		fmt.Println("starting ssh server on port 2222")
-		if err := s.ListenAndServe(); err != nil {
-			log.Fatalln(err)
+		slog.Info("starting ssh server", "address", *sshAddressFlag)
+		if err := s.ListenAndServe(); err != nil && !errors.Is(err, ssh.ErrServerClosed) {
+			fatal(err)
 		}
+		// Closed by a signal or restart; wait for the open sessions to wind down
+		<-stopped
 
 	} else {
-		p := tea.NewProgram(initialModel())
-		if _, err := p.Run(); err != nil {
+		if *overlayFlag != "" {
+			overlay = newOverlayHub()
+			go overlay.serve(*overlayFlag)
+		}
+
+		usage.session()
+		m := initialModel()
+		m.inline = *inlineFlag
+		m.speechCommand = *speechFlag
+		if clipSeconds > 0 && !m.inline {
+			m.recorder = newFrameRecorder(clipSeconds)
+		}
+		m.user = os.Getenv("USER")
+		m.identity = m.user
+		if notes, err := loadNotes(m.identity); err != nil {
+			m.logger.Error("loading notes", "err", err)
+		} else {
+			m.notes.SetValue(notes)
+		}
+		m.applyPreferences(sessionEnv(os.Environ(), acceptedEnv))
+		p := tea.NewProgram(m)
+		_, err := p.Run()
+		if telemetryEndpoint != "" {
+			// A local visit rarely lasts an interval, so report on the way out
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			if err := sendTelemetry(ctx); err != nil {
+				slog.Error("reporting telemetry", "err", err)
+			}
+			cancel()
+		}
+		if err != nil {
 			fmt.Printf("Error running program: %v\n", err)
 			os.Exit(1)
 		}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Markdown answers are drawn with the session's renderer, so styles degrade
+// with the client's color profile. Only what answers actually use is
+// supported: headings, lists, quotes, code and inline emphasis.
+
+var (
+	mdHeading   = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	mdBullet    = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	mdNumbered  = regexp.MustCompile(`^(\s*)(\d+[.)])\s+(.*)$`)
+	mdQuote     = regexp.MustCompile(`^>\s?(.*)$`)
+	mdRule      = regexp.MustCompile(`^\s*(-\s*){3,}$|^\s*(\*\s*){3,}$|^\s*(_\s*){3,}$`)
+	mdFence     = regexp.MustCompile("^\\s*(```|~~~)")
+	mdCodeSpan  = regexp.MustCompile("`([^`]+)`")
+	mdStrong    = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	mdEmphasis  = regexp.MustCompile(`\*([^*\s][^*]*)\*|\b_([^_\s][^_]*)_\b`)
+	mdLink      = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdBlockHint = regexp.MustCompile("(?m)^(#{1,6}\\s|\\s*[-*+]\\s|\\s*\\d+[.)]\\s|>|\\s*```)")
+)
+
+// looksLikeMarkdown reports whether an answer uses markdown worth rendering.
+func looksLikeMarkdown(text string) bool {
+	return mdBlockHint.MatchString(text) || mdStrong.MatchString(text) || mdCodeSpan.MatchString(text)
+}
+
+// renderMarkdown renders a markdown answer width cells wide.
+func renderMarkdown(text string, width int, newStyle func() lipgloss.Style) string {
+	var out, paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			out = append(out, newStyle().Width(width).Render(mdInline(strings.Join(paragraph, " "), newStyle)))
+			paragraph = nil
+		}
+	}
+	code := newStyle().Foreground(lipgloss.Color("180"))
+	fenced := false
+	for _, line := range strings.Split(text, "\n") {
+		if mdFence.MatchString(line) {
+			flush()
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			// Code keeps its lines; cut by cells so wide runes don't spill over
+			out = append(out, code.MaxWidth(width).Render("  "+line))
+			continue
+		}
+		if m := mdHeading.FindStringSubmatch(line); m != nil {
+			flush()
+			heading := newStyle().Bold(true).Foreground(lipgloss.Color("#FFF")).Width(width)
+			if len(m[1]) == 1 {
+				heading = heading.Underline(true)
+			}
+			out = append(out, heading.Render(mdInline(m[2], newStyle)))
+			continue
+		}
+		if m := mdBullet.FindStringSubmatch(line); m != nil && !mdRule.MatchString(line) {
+			flush()
+			out = append(out, mdItem(m[1]+"• ", m[2], width, newStyle))
+			continue
+		}
+		if m := mdNumbered.FindStringSubmatch(line); m != nil {
+			flush()
+			out = append(out, mdItem(m[1]+m[2]+" ", m[3], width, newStyle))
+			continue
+		}
+		if m := mdQuote.FindStringSubmatch(line); m != nil {
+			flush()
+			quote := newStyle().Italic(true).Foreground(lipgloss.Color("245")).Width(width - 2).Render(mdInline(m[1], newStyle))
+			out = append(out, mdHang("│ ", "│ ", quote))
+			continue
+		}
+		if mdRule.MatchString(line) {
+			flush()
+			out = append(out, newStyle().Foreground(lipgloss.Color("240")).Render(strings.Repeat("─", width)))
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			flush()
+			out = append(out, "")
+			continue
+		}
+		paragraph = append(paragraph, strings.TrimSpace(line))
+	}
+	flush()
+	return strings.Join(out, "\n")
+}
+
+// mdItem renders a list item with its text hanging after the marker.
+func mdItem(marker, text string, width int, newStyle func() lipgloss.Style) string {
+	body := newStyle().Width(max(width-lipgloss.Width(marker), 1)).Render(mdInline(text, newStyle))
+	return mdHang(marker, strings.Repeat(" ", lipgloss.Width(marker)), body)
+}
+
+// mdHang puts first before the first line of body and rest before the others.
+func mdHang(first, rest, body string) string {
+	lines := strings.Split(body, "\n")
+	for i := range lines {
+		if i == 0 {
+			lines[i] = first + lines[i]
+		} else {
+			lines[i] = rest + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// mdInline styles code spans, strong and emphasized text and links.
+func mdInline(text string, newStyle func() lipgloss.Style) string {
+	code := newStyle().Foreground(lipgloss.Color("180"))
+	var b strings.Builder
+	last := 0
+	for _, span := range mdCodeSpan.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(mdEmphasize(text[last:span[0]], newStyle))
+		b.WriteString(code.Render(text[span[2]:span[3]]))
+		last = span[1]
+	}
+	b.WriteString(mdEmphasize(text[last:], newStyle))
+	return b.String()
+}
+
+func mdEmphasize(text string, newStyle func() lipgloss.Style) string {
+	group := func(m []string) string {
+		if m[1] != "" {
+			return m[1]
+		}
+		return m[2]
+	}
+	text = mdLink.ReplaceAllStringFunc(text, func(s string) string {
+		return newStyle().Underline(true).Render(mdLink.FindStringSubmatch(s)[1])
+	})
+	text = mdStrong.ReplaceAllStringFunc(text, func(s string) string {
+		return newStyle().Bold(true).Render(group(mdStrong.FindStringSubmatch(s)))
+	})
+	return mdEmphasis.ReplaceAllStringFunc(text, func(s string) string {
+		return newStyle().Italic(true).Render(group(mdEmphasis.FindStringSubmatch(s)))
+	})
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// A stand-in for the wisdom API, for local development, demos and load
+// tests: orb mockserver --latency 2s --failure-rate 0.1
+type mockServer struct {
+	corpus      []string
+	latency     time.Duration
+	jitter      time.Duration
+	failureRate float64       // Share of questions answered with a 503
+	wordDelay   time.Duration // Pause between the words of a streamed answer
+	expiresIn   int
+}
+
+func runMockserver(args []string) {
+	flags := flag.NewFlagSet("mockserver", flag.ExitOnError)
+	addr := flags.String("addr", "localhost:8080", "address the mock wisdom API listens on")
+	latency := flags.Duration("latency", 500*time.Millisecond, "how long each answer takes")
+	jitter := flags.Duration("jitter", 250*time.Millisecond, "random extra latency, up to this much")
+	failureRate := flags.Float64("failure-rate", 0, "share of questions answered with a 503, from 0 to 1")
+	corpus := flags.String("corpus", "", "file of canned answers, one per line (default the bundled fortunes)")
+	wordDelay := flags.Duration("word-delay", 80*time.Millisecond, "pause between the words of a streamed answer")
+	expiresIn := flags.Int("expires-in", 0, "seconds answers say they stay valid (0 leaves it out)")
+	flags.Parse(args)
+
+	if *failureRate < 0 || *failureRate > 1 {
+		fmt.Fprintln(os.Stderr, "--failure-rate must be between 0 and 1")
+		os.Exit(2)
+	}
+	m := &mockServer{
+		corpus:      fortunes,
+		latency:     *latency,
+		jitter:      *jitter,
+		failureRate: *failureRate,
+		wordDelay:   *wordDelay,
+		expiresIn:   *expiresIn,
+	}
+	if *corpus != "" {
+		data, err := os.ReadFile(*corpus)
+		if err != nil {
+			fatal(fmt.Errorf("failed to read corpus: %w", err))
+		}
+		if m.corpus = parseFortunes(string(data)); len(m.corpus) == 0 {
+			fatal(fmt.Errorf("corpus %s has no answers", *corpus))
+		}
+	}
+
+	slog.Info("mock wisdom API listening", "address", *addr, "answers", len(m.corpus),
+		"hint", fmt.Sprintf("orb --endpoint http://%s/ --allow-private-addrs", *addr))
+	if err := http.ListenAndServe(*addr, m); err != nil {
+		fatal(err)
+	}
+}
+
+// answer picks the canned answer for a question; the same question always
+// gets the same one.
+func (m *mockServer) answer(question string) string {
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(strings.TrimSpace(question))))
+	return m.corpus[h.Sum32()%uint32(len(m.corpus))]
+}
+
+func (m *mockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "ask with a POST", http.StatusMethodNotAllowed)
+		return
+	}
+	var payload questionPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || strings.TrimSpace(payload.Question) == "" {
+		http.Error(w, "want a JSON question", http.StatusBadRequest)
+		return
+	}
+	logger := slog.With("request", r.Header.Get("X-Request-ID"))
+
+	delay := m.latency
+	if m.jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(m.jitter)))
+	}
+	select {
+	case <-time.After(delay):
+	case <-r.Context().Done():
+		return
+	}
+	if rand.Float64() < m.failureRate {
+		logger.Info("failing question on purpose")
+		http.Error(w, "the cosmos is clouded", http.StatusServiceUnavailable)
+		return
+	}
+
+	answer := m.answer(payload.Question)
+	logger.Info("answered", "delay", delay, "streamed", wantsStream(r))
+	if !wantsStream(r) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(wisdomResponse{Wisdom: answer, ExpiresIn: m.expiresIn})
+		return
+	}
+
+	w.Header().Set("Content-Type", wisdomStreamType)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	words := strings.Fields(answer)
+	for i, word := range words {
+		if i < len(words)-1 {
+			word += " "
+		}
+		encoder.Encode(wisdomChunk{Wisdom: word})
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case <-time.After(m.wordDelay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	encoder.Encode(wisdomChunk{Done: true, ExpiresIn: m.expiresIn})
+}
+
+// wantsStream reports whether the client asked for the answer a piece at a
+// time.
+func wantsStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), wisdomStreamType)
+}
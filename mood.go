@@ -0,0 +1,72 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// How long an answer colors the orb's mood
+const moodDuration = time.Minute
+
+// Hues the orb leans toward for positive and negative answers
+const (
+	warmHue = 30.0
+	coolHue = 210.0
+)
+
+// Words that lift or sink the mood of an answer
+var (
+	positiveWords = map[string]bool{
+		"yes": true, "joy": true, "love": true, "light": true, "hope": true,
+		"bright": true, "good": true, "gain": true, "success": true, "warm": true,
+		"growth": true, "peace": true, "bloom": true, "fortune": true, "embrace": true,
+	}
+	negativeWords = map[string]bool{
+		"no": true, "not": true, "loss": true, "dark": true, "fear": true,
+		"beware": true, "cold": true, "storm": true, "shadow": true, "fail": true,
+		"doubt": true, "sorrow": true, "wait": true, "never": true, "danger": true,
+	}
+)
+
+// sentiment scores text from -1 (gloomy) to 1 (joyful) by counting mood words.
+func sentiment(text string) float64 {
+	var pos, neg int
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		word = strings.Trim(word, ".,!?;:\"'()")
+		if positiveWords[word] {
+			pos++
+		} else if negativeWords[word] {
+			neg++
+		}
+	}
+	if pos+neg == 0 {
+		return 0
+	}
+	return float64(pos-neg) / float64(pos+neg)
+}
+
+// The orb's lingering reaction to its last answer
+type mood struct {
+	warmth float64   // -1 cool to 1 warm
+	until  time.Time // When the mood has fully faded
+}
+
+func newMood(answer string) mood {
+	return mood{warmth: sentiment(answer), until: time.Now().Add(moodDuration)}
+}
+
+// apply pulls a hue toward warm or cool, fading out as the mood expires.
+func (md mood) apply(hue float64, now time.Time) float64 {
+	remaining := md.until.Sub(now)
+	if md.warmth == 0 || remaining <= 0 {
+		return hue
+	}
+
+	target := warmHue
+	if md.warmth < 0 {
+		target = coolHue
+	}
+	faded := ease(progress(md.until.Add(-moodDuration), moodDuration, now))
+	return lerpHue(hue, target, math.Abs(md.warmth)*0.6*(1-faded))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/textarea"
+)
+
+// Directory notes are persisted to per user, set by --notes-dir; empty
+// keeps notes for the session only
+var notesDir = ""
+
+func newNotesArea() textarea.Model {
+	ta := textarea.New()
+	ta.Placeholder = "Jot down your thoughts between questions..."
+	ta.ShowLineNumbers = false
+	ta.CharLimit = 2000
+	ta.SetHeight(5)
+	return ta
+}
+
+// notesPath returns where an identity's notes live, or "" when not
+// persisted. Sessions without a verified identity keep their notes in
+// memory only.
+func notesPath(identity string) string {
+	if notesDir == "" || identity == "" {
+		return ""
+	}
+	// Keep identities from escaping the notes directory
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == '.' {
+			return '_'
+		}
+		return r
+	}, identity)
+	return filepath.Join(notesDir, name+".txt")
+}
+
+func loadNotes(identity string) (string, error) {
+	path := notesPath(identity)
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read notes: %w", err)
+	}
+	return string(data), nil
+}
+
+func saveNotes(identity, text string) error {
+	path := notesPath(identity)
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(notesDir, 0700); err != nil {
+		return fmt.Errorf("failed to create notes dir: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(text), 0600); err != nil {
+		return fmt.Errorf("failed to write notes: %w", err)
+	}
+	return nil
+}
+
+// Introduces the notes attached to a question
+const notesHeader = "\n\nFor context, my notes so far:\n"
+
+// withNotes attaches the scratchpad to a question as context. Questions are
+// capped at maxQuestionRunes, so only as much of the notes as fits is sent;
+// truncated reports whether some were left out.
+func withNotes(question, notes string) (sent string, truncated bool) {
+	budget := maxQuestionRunes - utf8.RuneCountInString(question) - utf8.RuneCountInString(notesHeader)
+	notes = strings.TrimSpace(notes)
+	if budget <= 0 {
+		return question, true
+	}
+	if runes := []rune(notes); len(runes) > budget {
+		return question + notesHeader + string(runes[:budget]), true
+	}
+	return question + notesHeader + notes, false
+}
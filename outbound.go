@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Hosts the orb may send requests to, set from --allow-hosts and the
+// configured endpoints
+var allowedHosts = map[string]bool{}
+
+// Whether outbound requests may reach loopback and private addresses
+var allowPrivateAddrs = false
+
+// Operator-configured local services (such as Ollama) that may resolve to
+// private addresses even when allowPrivateAddrs is off
+var privateHosts = map[string]bool{}
+
+// allowEndpointHosts adds the host of each endpoint URL to the allowlist.
+func allowEndpointHosts(endpoints ...string) {
+	for _, endpoint := range endpoints {
+		if endpoint == "" {
+			continue
+		}
+		if u, err := url.Parse(endpoint); err == nil && u.Hostname() != "" {
+			allowedHosts[strings.ToLower(u.Hostname())] = true
+		}
+	}
+}
+
+// allowLocalEndpoint allowlists an endpoint that is expected to live on
+// this machine or the local network.
+func allowLocalEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("no host in %q", endpoint)
+	}
+	host := strings.ToLower(u.Hostname())
+	allowedHosts[host] = true
+	privateHosts[host] = true
+	return nil
+}
+
+// The client all outbound HTTP goes through. Its timeout, set by
+// --request-timeout, covers each attempt including reading the answer, so a
+// hung backend can't leave the orb thinking forever.
+var outboundClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: allowlistTransport{base: &http.Transport{
+		Proxy:               nil, // A proxy would resolve names for us and defeat the checks
+		DialContext:         dialPublic,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return fmt.Errorf("stopped after %d redirects", len(via))
+		}
+		return checkHost(req.URL.Hostname())
+	},
+}
+
+func checkHost(host string) error {
+	if !allowedHosts[strings.ToLower(host)] {
+		return fmt.Errorf("outbound host %q is not on the allowlist", host)
+	}
+	return nil
+}
+
+// Refuses requests to hosts that aren't allowlisted
+type allowlistTransport struct {
+	base http.RoundTripper
+}
+
+func (t allowlistTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := checkHost(req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// dialPublic resolves the host once, rejects private and loopback addresses
+// and connects to the vetted IP, so a DNS answer can't change between the
+// check and the connection (DNS rebinding).
+func dialPublic(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var lastErr error = fmt.Errorf("no usable addresses for %s", host)
+	for _, ip := range ips {
+		if !allowPrivateAddrs && !privateHosts[strings.ToLower(host)] && isPrivateIP(ip.IP) {
+			lastErr = fmt.Errorf("refusing to connect to %s: %s is not a public address", host, ip.IP)
+			continue
+		}
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.IP.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// Ranges the net.IP helpers don't cover: carrier-grade NAT (often used
+// for internal networks) and "this network"
+var reservedNets = []*net.IPNet{
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("0.0.0.0/8"),
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func isPrivateIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return true
+	}
+	for _, n := range reservedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Size of the orb mirrored to the overlay
+const overlayOrbWidth = 60
+
+// What the overlay needs to know about the running orb
+type overlayState struct {
+	frame    int
+	theme    theme
+	question string
+	answer   string
+}
+
+// The JSON message pushed to overlay pages
+type overlayUpdate struct {
+	Orb      string `json:"orb"`
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+	Lang     string `json:"lang"` // Detected language of the answer, "und" when unknown
+}
+
+// overlayHub mirrors the orb to browser sources over WebSocket.
+type overlayHub struct {
+	mu      sync.Mutex
+	state   overlayState
+	clients map[net.Conn]bool
+}
+
+// The hub for the --overlay endpoint, nil when the overlay is off
+var overlay *overlayHub
+
+func newOverlayHub() *overlayHub {
+	return &overlayHub{clients: make(map[net.Conn]bool)}
+}
+
+// publish records the latest orb state; it's picked up by the next broadcast.
+func (h *overlayHub) publish(state overlayState) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.state = state
+}
+
+// serve starts the overlay HTTP endpoint and pushes updates ten times a second.
+func (h *overlayHub) serve(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, overlayPage)
+	})
+	mux.HandleFunc("/ws", h.handleWebSocket)
+
+	go func() {
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for range ticker.C {
+			h.broadcast()
+		}
+	}()
+
+	if err := http.ListenAndServe(overlayListenAddr(addr), mux); err != nil {
+		slog.Error("overlay server stopped", "err", err)
+	}
+}
+
+// overlayListenAddr binds to loopback when addr names no host, since the
+// overlay is meant for a streaming app on the same machine.
+func overlayListenAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host != "" {
+		return addr
+	}
+	return net.JoinHostPort("127.0.0.1", port)
+}
+
+// sameOrigin reports whether a browser request came from a page this server
+// served. Non-browser clients send no Origin header and are let through.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+func (h *overlayHub) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin websocket requests are not allowed", http.StatusForbidden)
+		return
+	}
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	h.clients[conn] = true
+	h.mu.Unlock()
+
+	// Browser sources never talk back; a read error means they went away
+	go func() {
+		io.Copy(io.Discard, conn)
+		h.mu.Lock()
+		delete(h.clients, conn)
+		h.mu.Unlock()
+		conn.Close()
+	}()
+}
+
+func (h *overlayHub) broadcast() {
+	h.mu.Lock()
+	state := h.state
+	clients := make([]net.Conn, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	h.mu.Unlock()
+
+	if len(clients) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(overlayUpdate{
+		Orb:      renderOrbHTML(state),
+		Question: state.question,
+		Answer:   state.answer,
+		Lang:     detectLanguage(state.answer),
+	})
+	if err != nil {
+		return
+	}
+	for _, c := range clients {
+		c.SetWriteDeadline(time.Now().Add(time.Second))
+		if err := writeWebSocketText(c, payload); err != nil {
+			c.Close()
+		}
+	}
+}
+
+// renderOrbHTML draws the orb as rows of colored spans.
+func renderOrbHTML(state overlayState) string {
+	radius := overlayOrbWidth / 4
+	orbHeight := radius * 2
+	visibleOrbHeight := int(float64(orbHeight) * 0.6)
+	frame := state.theme.swirlFrame(state.frame)
+	palette := state.theme.palette(state.theme.baseHue(frame))
+
+	var b strings.Builder
+	for y := 0; y < visibleOrbHeight; y++ {
+		for x := 0; x < overlayOrbWidth; x++ {
+			color, ok := orbPixelColor(x, y, overlayOrbWidth, orbHeight, radius, frame, palette, state.theme.rim, noRing)
+			if !ok {
+				b.WriteString(" ")
+				continue
+			}
+			fmt.Fprintf(&b, `<span style="color:%s">█</span>`, html.EscapeString(string(color)))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// upgradeWebSocket performs the server side of the WebSocket handshake and
+// hands back the raw connection.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (net.Conn, error) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return nil, fmt.Errorf("expected a websocket upgrade")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, fmt.Errorf("missing Sec-WebSocket-Key")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, fmt.Errorf("connection can't be hijacked")
+	}
+
+	sum := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	accept := base64.StdEncoding.EncodeToString(sum[:])
+
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	if err := writeHandshake(rw, accept); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func writeHandshake(rw *bufio.ReadWriter, accept string) error {
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	rw.WriteString("Upgrade: websocket\r\n")
+	rw.WriteString("Connection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + accept + "\r\n\r\n")
+	return rw.Flush()
+}
+
+// writeWebSocketText sends payload as a single unmasked text frame.
+func writeWebSocketText(w io.Writer, payload []byte) error {
+	return writeWebSocketFrame(w, 0x1, payload)
+}
+
+// writeWebSocketFrame sends payload as a single unmasked frame.
+func writeWebSocketFrame(w io.Writer, opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode} // FIN + opcode
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n < 1<<16:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(n))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// Largest message a browser may send, far beyond any keystroke or resize
+const maxWebSocketMessage = 64 << 10
+
+// readWebSocketMessage reads one message from a browser, reassembling
+// fragments and unmasking them. Pings and pongs are skipped; a close frame
+// returns io.EOF.
+func readWebSocketMessage(r io.Reader) (opcode byte, payload []byte, err error) {
+	for {
+		var h [2]byte
+		if _, err := io.ReadFull(r, h[:]); err != nil {
+			return 0, nil, err
+		}
+		fin, op, masked := h[0]&0x80 != 0, h[0]&0x0f, h[1]&0x80 != 0
+		n := uint64(h[1] & 0x7f)
+		switch n {
+		case 126:
+			var b [2]byte
+			if _, err := io.ReadFull(r, b[:]); err != nil {
+				return 0, nil, err
+			}
+			n = uint64(binary.BigEndian.Uint16(b[:]))
+		case 127:
+			var b [8]byte
+			if _, err := io.ReadFull(r, b[:]); err != nil {
+				return 0, nil, err
+			}
+			n = binary.BigEndian.Uint64(b[:])
+		}
+		if n > maxWebSocketMessage || uint64(len(payload))+n > maxWebSocketMessage {
+			return 0, nil, fmt.Errorf("websocket message over %d bytes", maxWebSocketMessage)
+		}
+
+		var mask [4]byte
+		if masked {
+			if _, err := io.ReadFull(r, mask[:]); err != nil {
+				return 0, nil, err
+			}
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return 0, nil, err
+		}
+		if masked {
+			for i := range data {
+				data[i] ^= mask[i%4]
+			}
+		}
+
+		switch {
+		case op == 0x8:
+			return op, data, io.EOF
+		case op > 0x8:
+			continue
+		case op != 0:
+			opcode = op
+		}
+		payload = append(payload, data...)
+		if fin {
+			return opcode, payload, nil
+		}
+	}
+}
+
+// The browser source page: transparent background, orb plus latest Q&A
+const overlayPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Orb of Pondering</title>
+<style>
+  html, body { background: transparent; margin: 0; }
+  body { font-family: monospace; color: #fff; text-align: center; text-shadow: 0 0 4px #000; }
+  #orb { line-height: 1; font-size: 14px; margin: 0; }
+  #question { color: #ccc; margin-top: 1em; }
+  #answer { font-size: 20px; margin-top: 0.5em; }
+  #answer:lang(ja), #answer:lang(zh) { line-break: strict; }
+  #answer:lang(ko) { word-break: keep-all; }
+</style>
+</head>
+<body>
+<pre id="orb"></pre>
+<div id="question"></div>
+<div id="answer"></div>
+<script>
+function connect() {
+  const ws = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/ws");
+  ws.onmessage = (e) => {
+    const u = JSON.parse(e.data);
+    document.getElementById("orb").innerHTML = u.orb;
+    document.getElementById("question").textContent = u.question;
+    const answer = document.getElementById("answer");
+    answer.textContent = u.answer;
+    // Lets the browser wrap by the rules of the answer's script
+    answer.lang = u.lang === "und" ? "" : u.lang;
+    answer.dir = u.lang === "ar" || u.lang === "he" ? "rtl" : "auto";
+  };
+  ws.onclose = () => setTimeout(connect, 1000);
+}
+connect();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Answers longer than this advertise the pager key
+const longAnswerRunes = 280
+
+// A message for when the pager has been closed
+type pagerDoneMsg struct {
+	path string
+	err  error
+}
+
+// openPagerCmd suspends the TUI and shows text in $PAGER (less by default).
+func openPagerCmd(text string) tea.Cmd {
+	f, err := os.CreateTemp("", "orb-answer-*.txt")
+	if err != nil {
+		return func() tea.Msg { return pagerDoneMsg{err: err} }
+	}
+	_, err = f.WriteString(text + "\n")
+	f.Close()
+	if err != nil {
+		return func() tea.Msg { return pagerDoneMsg{path: f.Name(), err: err} }
+	}
+
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = "less"
+	}
+	c := exec.Command("sh", "-c", pager+` "$1"`, "sh", f.Name())
+	return tea.ExecProcess(c, func(err error) tea.Msg {
+		return pagerDoneMsg{path: f.Name(), err: err}
+	})
+}
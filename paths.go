@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The command that asks for several answers to choose between:
+//
+//	/paths should I take the job
+const pathsCommand = "/paths"
+
+// How many answers the orb offers for /paths
+const pathCount = 3
+
+// pathsPath is where chosen paths are recorded, beside the history.
+func pathsPath() string {
+	return filepath.Join(filepath.Dir(historyPath), "paths.jsonl")
+}
+
+// A message with the answers offered as paths
+type pathsMsg struct {
+	answers []string
+	notes   []string
+}
+
+// getPathsCmd asks the provider the same question pathCount times at once,
+// offering whichever answers come back.
+func getPathsCmd(ctx context.Context, question, persona, requestID, asker string, conversation []exchange) tea.Cmd {
+	return func() tea.Msg {
+		answers := make([]string, pathCount)
+		errs := make([]error, pathCount)
+		meta := &answerMeta{}
+		var wg sync.WaitGroup
+		for i := range pathCount {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ctx := withRequest(ctx, fmt.Sprintf("%s-%d", requestID, i+1), persona)
+				ctx = withConversation(withAsker(ctx, asker), conversation)
+				answers[i], errs[i] = wisdom.GetAnswer(withAnswerMeta(ctx, meta), question)
+			}()
+		}
+		wg.Wait()
+		if ctx.Err() != nil {
+			return interruptedMsg{}
+		}
+
+		var msg pathsMsg
+		for i, answer := range answers {
+			if errs[i] != nil {
+				loggerFrom(ctx).Error("getting path", "request", requestID, "path", i+1, "err", errs[i])
+				continue
+			}
+			msg.answers = append(msg.answers, cleanAnswer(answer, meta))
+		}
+		if len(msg.answers) == 0 {
+			countFailure(errs[0])
+			return errMsg{errs[0]}
+		}
+		msg.notes = meta.notes
+		return msg
+	}
+}
+
+// One choice between paths, as written to pathsPath()
+type pathRecord struct {
+	Time     time.Time `json:"time"`
+	Question string    `json:"question"`
+	Paths    []string  `json:"paths"`
+	Choice   int       `json:"choice"` // From 1
+}
+
+var pathsMu sync.Mutex
+
+// recordPath appends the path the asker chose.
+func recordPath(question string, paths []string, choice int) error {
+	data, err := json.Marshal(pathRecord{Time: time.Now(), Question: question, Paths: paths, Choice: choice})
+	if err != nil {
+		return fmt.Errorf("failed to encode path: %w", err)
+	}
+
+	pathsMu.Lock()
+	defer pathsMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(pathsPath()), 0755); err != nil {
+		return fmt.Errorf("failed to create paths directory: %w", err)
+	}
+	f, err := os.OpenFile(pathsPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open paths file: %w", err)
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// choosePath handles keys while paths are offered: a number follows that
+// path, esc turns away from them all.
+func (m model) choosePath(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch key := msg.String(); key {
+	case "ctrl+c":
+		return m.quit()
+	case "esc":
+		m.paths = nil
+		m.textInput.Focus()
+		return m, nil
+	default:
+		n, err := strconv.Atoi(key)
+		if err != nil || n < 1 || n > len(m.paths) {
+			return m, nil
+		}
+		if m.recording() {
+			if err := recordPath(m.question, m.paths, n); err != nil {
+				m.logger.Error("recording path", "err", err)
+			}
+		}
+		// The chosen path is the answer, and the conversation goes on from it
+		answer := m.paths[n-1]
+		m.paths = nil
+		m.thinking = true
+		return m.update(answerMsg{answer: answer, notes: m.pathNotes})
+	}
+}
+
+// renderPaths lays the paths out side by side, numbered to be chosen.
+func renderPaths(paths []string, width int, newStyle func() lipgloss.Style) string {
+	colWidth := max(width/len(paths)-4, 12)
+	var cols []string
+	for i, path := range paths {
+		label := newStyle().Bold(true).Foreground(lipgloss.Color("155")).Render(strconv.Itoa(i + 1))
+		body := newStyle().Width(colWidth).Render(path)
+		cols = append(cols, newStyle().Padding(0, 1).Render(lipgloss.JoinVertical(lipgloss.Center, label, body)))
+	}
+	hint := newStyle().Padding(1, 2, 0).Foreground(lipgloss.Color("240")).
+		Render(fmt.Sprintf("The orb offers %d paths. Which will you walk? [1-%d] · none [esc]", len(paths), len(paths)))
+	return lipgloss.JoinVertical(lipgloss.Center,
+		newStyle().Padding(1, 0, 0).Render(lipgloss.JoinHorizontal(lipgloss.Top, cols...)),
+		hint,
+	)
+}
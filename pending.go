@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// How long a finished answer waits for its asker to reconnect
+const pendingAnswerTTL = 24 * time.Hour
+
+// An answer that finished after its asker disconnected
+type pendingAnswer struct {
+	question string
+	answer   string
+	expires  time.Time
+	finished time.Time
+}
+
+// Answers waiting for their users, keyed by public key fingerprint
+type pendingStore struct {
+	mu      sync.Mutex
+	answers map[string]pendingAnswer
+}
+
+var pendingAnswers = &pendingStore{answers: make(map[string]pendingAnswer)}
+
+func (p *pendingStore) put(identity string, a pendingAnswer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.answers[identity] = a
+}
+
+// take removes and returns the pending answer for an identity, if a fresh one exists.
+func (p *pendingStore) take(identity string) (pendingAnswer, bool) {
+	if identity == "" {
+		return pendingAnswer{}, false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	a, ok := p.answers[identity]
+	delete(p.answers, identity)
+	if !ok || time.Since(a.finished) > pendingAnswerTTL {
+		return pendingAnswer{}, false
+	}
+	return a, true
+}
+
+// deliverIfGone wraps an answer command so that an answer arriving after the
+// session ended is kept for the user's next connection instead of being lost.
+func deliverIfGone(session context.Context, identity, question string, cmd tea.Cmd) tea.Cmd {
+	return func() tea.Msg {
+		msg := cmd()
+		if chunk, ok := msg.(streamChunkMsg); ok {
+			chunk.next = deliverIfGone(session, identity, question, chunk.next)
+			if session.Err() != nil {
+				// Nobody is reading the stream any more, so drain it here
+				return chunk.next()
+			}
+			return chunk
+		}
+		if session.Err() == nil {
+			return msg
+		}
+		if a, ok := msg.(answerMsg); ok {
+			pendingAnswers.put(identity, pendingAnswer{question: question, answer: a.answer, expires: a.expires, finished: time.Now()})
+		}
+		return msg
+	}
+}
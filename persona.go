@@ -0,0 +1,32 @@
+package main
+
+// Personas the backend knows how to speak as, selectable per session with
+// ORB_PERSONA. The empty persona leaves the choice to the backend.
+var personas = []string{"orb", "genie", "seer"}
+
+// System prompts for providers that talk to a model directly; these match
+// the ones the wisdom API uses.
+var personaPrompts = map[string]string{
+	"orb":   "You are a mystical orb of pondering that people come to for wisdom. You will provide advice or insight that is deep and reflective but must be extremely concise. Sort of like a prophetic magic 8-ball or a chinese fortune cookie. a wise guru giving spiritual guidance",
+	"genie": "You are a playful genie bound to a glowing orb. You grant wisdom instead of wishes, with theatrical flair, but your answers must be extremely concise.",
+	"seer":  "You are an ancient, cryptic seer gazing into an orb. You answer in short riddles and omens that hint at the truth without stating it plainly.",
+}
+
+func validPersona(name string) bool {
+	for _, p := range personas {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// systemPrompt returns the persona's prompt, hardened against the question
+// posing as instructions.
+func systemPrompt(persona string) string {
+	prompt, ok := personaPrompts[persona]
+	if !ok {
+		prompt = personaPrompts["orb"]
+	}
+	return prompt + delimiterRules
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often the pin file is checked for changes
+const pinReloadInterval = 5 * time.Second
+
+// A prophecy from the history, shown beneath the header for everyone
+// until it expires: the wisdom of the week
+type pinnedProphecy struct {
+	RequestID string    `json:"request_id"`
+	Question  string    `json:"question"`
+	Answer    string    `json:"answer"`
+	Until     time.Time `json:"until"`
+}
+
+// pinPath is where the pinned prophecy is kept, beside the history.
+func pinPath() string {
+	return filepath.Join(filepath.Dir(historyPath), "pinned.json")
+}
+
+// The pinned prophecy as last read from the pin file
+type pinBoard struct {
+	mu       sync.RWMutex
+	pin      *pinnedProphecy
+	modified time.Time
+}
+
+var pinned = &pinBoard{}
+
+// reload rereads the pin file if it changed since it was last read.
+func (b *pinBoard) reload() error {
+	info, err := os.Stat(pinPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		b.mu.Lock()
+		b.pin, b.modified = nil, time.Time{}
+		b.mu.Unlock()
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat pin: %w", err)
+	}
+	b.mu.RLock()
+	unchanged := info.ModTime().Equal(b.modified)
+	b.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	pin, err := readPin()
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.pin, b.modified = pin, info.ModTime()
+	b.mu.Unlock()
+	return nil
+}
+
+// watch polls the pin file, so orb pin takes effect in running sessions.
+func (b *pinBoard) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := b.reload(); err != nil {
+			slog.Error("reloading pin", "err", err)
+		}
+	}
+}
+
+// current returns the pinned prophecy, unless there is none or it expired.
+func (b *pinBoard) current(now time.Time) (pinnedProphecy, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.pin == nil || now.After(b.pin.Until) {
+		return pinnedProphecy{}, false
+	}
+	return *b.pin, true
+}
+
+func readPin() (*pinnedProphecy, error) {
+	data, err := os.ReadFile(pinPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pin: %w", err)
+	}
+	var pin pinnedProphecy
+	if err := json.Unmarshal(data, &pin); err != nil {
+		return nil, fmt.Errorf("failed to parse pin: %w", err)
+	}
+	return &pin, nil
+}
+
+// writePin atomically replaces the pin file.
+func writePin(pin pinnedProphecy) error {
+	data, err := json.MarshalIndent(pin, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode pin: %w", err)
+	}
+	tmp := pinPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write pin: %w", err)
+	}
+	if err := os.Rename(tmp, pinPath()); err != nil {
+		return fmt.Errorf("failed to write pin: %w", err)
+	}
+	return nil
+}
+
+// findProphecy looks up an answered question in the history by its request
+// ID, or by a prefix of it that only one record has.
+func findProphecy(id string) (historyRecord, error) {
+	historyMu.Lock()
+	records, err := readHistoryFile()
+	historyMu.Unlock()
+	if err != nil {
+		return historyRecord{}, err
+	}
+	var found []historyRecord
+	for _, rec := range records {
+		if rec.RequestID == id {
+			found = []historyRecord{rec}
+			break
+		}
+		if strings.HasPrefix(rec.RequestID, id) && !rec.Failed {
+			found = append(found, rec)
+		}
+	}
+	switch {
+	case len(found) == 0:
+		return historyRecord{}, fmt.Errorf("no answer with request ID %q in %s", id, historyPath)
+	case len(found) > 1:
+		return historyRecord{}, fmt.Errorf("%d answers have request IDs starting %q", len(found), id)
+	case found[0].Failed:
+		return historyRecord{}, fmt.Errorf("request %s was never answered", found[0].RequestID)
+	}
+	return found[0], nil
+}
+
+// runPin pins a prophecy from the history for every session to see:
+//
+//	orb pin 3f9c2a            pin the answer to request 3f9c2a… for a week
+//	orb pin --for 24h 3f9c2a  pin it for a day
+//	orb pin --clear           take the pin down
+//	orb pin                   show what's pinned
+func runPin(args []string) {
+	flags := flag.NewFlagSet("pin", flag.ExitOnError)
+	flags.StringVar(&historyPath, "history", historyPath, "history to pin from; the pin is kept beside it")
+	period := flags.Duration("for", 7*24*time.Hour, "how long the prophecy stays pinned")
+	clear := flags.Bool("clear", false, "take down the pinned prophecy")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: orb pin [flags] [request-id]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	switch {
+	case *clear:
+		if err := os.Remove(pinPath()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "failed to clear pin: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("nothing is pinned")
+	case flags.NArg() == 0:
+		pin, err := readPin()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if pin == nil || time.Now().After(pin.Until) {
+			fmt.Println("nothing is pinned")
+			return
+		}
+		fmt.Printf("pinned until %s (request %s)\n? %s\n%s\n", pin.Until.Format(time.RFC1123), pin.RequestID, pin.Question, pin.Answer)
+	default:
+		rec, err := findProphecy(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		pin := pinnedProphecy{RequestID: rec.RequestID, Question: rec.Question, Answer: rec.Answer, Until: time.Now().Add(*period)}
+		if err := writePin(pin); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("pinned until %s:\n%s\n", pin.Until.Format(time.RFC1123), pin.Answer)
+	}
+}
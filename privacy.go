@@ -0,0 +1,25 @@
+package main
+
+// Whether no one's questions are recorded in the history, set by --no-log
+var noLog = false
+
+// The key that stops recording a session's questions, and starts it again
+const privateKey = "ctrl+g"
+
+// recording reports whether the session's questions go into the history.
+func (m model) recording() bool {
+	return !noLog && !m.private
+}
+
+// privacyNotice tells the asker whether their questions are kept, since
+// nothing else on screen would.
+func (m model) privacyNotice() string {
+	switch {
+	case noLog:
+		return "This orb keeps no record of your questions."
+	case m.private:
+		return "Your questions aren't being recorded; Ctrl+G records them again."
+	default:
+		return "Your questions are recorded in the orb's history; Ctrl+G stops that."
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// WisdomProvider answers questions put to the orb.
+type WisdomProvider interface {
+	GetAnswer(ctx context.Context, question string) (string, error)
+}
+
+// Builds a provider from the current settings
+type providerFactory func() (WisdomProvider, error)
+
+// Providers selectable with --provider, filled in by each provider's init
+var providerRegistry = map[string]providerFactory{}
+
+const defaultProvider = "ponder"
+
+func registerProvider(name string, factory providerFactory) {
+	providerRegistry[name] = factory
+}
+
+// newProvider builds the named provider.
+func newProvider(name string) (WisdomProvider, error) {
+	factory, ok := providerRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q (available: %s)", name, strings.Join(providerNames(), ", "))
+	}
+	provider, err := factory()
+	if err != nil {
+		return nil, err
+	}
+	return limitProvider(name, provider), nil
+}
+
+func providerNames() []string {
+	var names []string
+	for name := range providerRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// The provider answering questions, replaced at startup by --provider
+var wisdom WisdomProvider = &ponderProvider{endpoint: wisdomEndpoint}
+
+// Name of the provider in use, for logs and records
+var wisdomName = defaultProvider
+
+// setupWisdom builds the provider questions go to from --provider, then
+// layers --fortunes, --offline, --routes and --rules over it.
+func setupWisdom(providers string, offline bool, fortunes, routes, rules string) error {
+	provider, err := newProviderChain(providers)
+	if err != nil {
+		return err
+	}
+	wisdom, wisdomName = provider, providers
+	if fortunes != "" {
+		if err := loadFortunes(fortunes); err != nil {
+			return err
+		}
+	}
+	if offline {
+		wisdom, wisdomName = fortuneProvider{}, "offline"
+	}
+	if routes != "" {
+		router, err := newRoutingProvider(routes, wisdom)
+		if err != nil {
+			return err
+		}
+		wisdom = router
+	}
+	if rules != "" {
+		canned, err := newCannedProvider(rules, wisdom)
+		if err != nil {
+			return err
+		}
+		wisdom = canned
+	}
+	return nil
+}
+
+// Request-scoped values providers may use
+type requestKey int
+
+const (
+	requestIDKey requestKey = iota
+	personaKey
+	modelKey
+	askerKey
+	conversationKey
+	loggerKey
+)
+
+// withRequest attaches the request ID and persona for a question to ctx.
+func withRequest(ctx context.Context, requestID, persona string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey, requestID)
+	return context.WithValue(ctx, personaKey, persona)
+}
+
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// withAsker tells providers who is asking, so busy ones can take turns.
+func withAsker(ctx context.Context, asker string) context.Context {
+	return context.WithValue(ctx, askerKey, asker)
+}
+
+func askerFrom(ctx context.Context) string {
+	asker, _ := ctx.Value(askerKey).(string)
+	return asker
+}
+
+// withLogger has providers log with the session's tags.
+func withLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// loggerFrom returns the logger for this question, or the default one.
+func loggerFrom(ctx context.Context) *slog.Logger {
+	if logger, _ := ctx.Value(loggerKey).(*slog.Logger); logger != nil {
+		return logger
+	}
+	return slog.Default()
+}
+
+// withPersona overrides the persona a question is answered in.
+func withPersona(ctx context.Context, persona string) context.Context {
+	return context.WithValue(ctx, personaKey, persona)
+}
+
+// withModel asks model-backed providers to answer with a specific model.
+func withModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, modelKey, model)
+}
+
+// modelFrom returns the model requested for this question, or fallback.
+func modelFrom(ctx context.Context, fallback string) string {
+	if model, _ := ctx.Value(modelKey).(string); model != "" {
+		return model
+	}
+	return fallback
+}
+
+func personaFrom(ctx context.Context) string {
+	p, _ := ctx.Value(personaKey).(string)
+	return p
+}
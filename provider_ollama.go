@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Ollama settings, set by --ollama-url and --ollama-model
+var (
+	ollamaURL   = "http://localhost:11434"
+	ollamaModel = "llama3.2"
+)
+
+func init() {
+	registerProvider("ollama", func() (WisdomProvider, error) {
+		if err := allowLocalEndpoint(ollamaURL); err != nil {
+			return nil, fmt.Errorf("invalid ollama url: %w", err)
+		}
+		return &ollamaProvider{baseURL: strings.TrimRight(ollamaURL, "/"), model: ollamaModel}, nil
+	})
+}
+
+// ollamaProvider consults a model served by a local Ollama instance.
+type ollamaProvider struct {
+	baseURL string
+	model   string
+}
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+}
+
+type ollamaChatResponse struct {
+	Message ollamaMessage `json:"message"`
+	Done    bool          `json:"done"`
+	Error   string        `json:"error"`
+}
+
+// chat sends the question to Ollama, asking for a streamed reply or not.
+func (p *ollamaProvider) chat(ctx context.Context, question string, stream bool) (*http.Response, error) {
+	messages := []ollamaMessage{{Role: "system", Content: systemPrompt(personaFrom(ctx)) + languageRule(question)}}
+	for _, ex := range conversationFrom(ctx) {
+		messages = append(messages,
+			ollamaMessage{Role: "user", Content: wrapQuestion(ex.Question)},
+			ollamaMessage{Role: "assistant", Content: ex.Answer},
+		)
+	}
+	payload := ollamaChatRequest{
+		Model:    modelFrom(ctx, p.model),
+		Messages: append(messages, ollamaMessage{Role: "user", Content: wrapQuestion(question)}),
+		Stream:   stream,
+	}
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal question: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/api/chat", bytes.NewBuffer(payloadBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build ollama request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := sendWithRetries(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach ollama: %w", err)
+	}
+	return resp, nil
+}
+
+func (p *ollamaProvider) GetAnswer(ctx context.Context, question string) (string, error) {
+	resp, err := p.chat(ctx, question, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var chatResp ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return "", fmt.Errorf("failed to decode ollama response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", statusError("ollama", resp.StatusCode, chatResp.Error)
+	}
+
+	answer := strings.TrimSpace(chatResp.Message.Content)
+	if answer == "" {
+		return "", fmt.Errorf("ollama returned an empty answer")
+	}
+	return answer, nil
+}
+
+// StreamAnswer reads Ollama's reply as it's generated, one JSON object per line.
+func (p *ollamaProvider) StreamAnswer(ctx context.Context, question string, onChunk func(string)) (string, error) {
+	resp, err := p.chat(ctx, question, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var answer strings.Builder
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var chunk ollamaChatResponse
+		if err := decoder.Decode(&chunk); err != nil {
+			return "", fmt.Errorf("failed to decode ollama stream: %w", err)
+		}
+		if chunk.Error != "" {
+			return "", statusError("ollama", resp.StatusCode, chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			answer.WriteString(chunk.Message.Content)
+			onChunk(chunk.Message.Content)
+		}
+		if chunk.Done {
+			break
+		}
+	}
+
+	result := strings.TrimSpace(answer.String())
+	if result == "" {
+		return "", fmt.Errorf("ollama returned an empty answer")
+	}
+	return result, nil
+}
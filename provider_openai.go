@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// OpenAI-compatible settings, set by the --openai-* flags
+var (
+	openaiURL          = "https://api.openai.com/v1"
+	openaiModel        = "gpt-4o-mini"
+	openaiTemperature  = 0.7
+	openaiKeyFile      = ""
+	openaiSystemPrompt = "" // Empty uses the persona's prompt
+)
+
+func init() {
+	registerProvider("openai", func() (WisdomProvider, error) {
+		key, err := openaiKey()
+		if err != nil {
+			return nil, err
+		}
+		allowEndpointHosts(openaiURL)
+		return &openaiProvider{
+			baseURL:      strings.TrimRight(openaiURL, "/"),
+			apiKey:       key,
+			model:        openaiModel,
+			temperature:  openaiTemperature,
+			systemPrompt: openaiSystemPrompt,
+		}, nil
+	})
+}
+
+// openaiKey reads the API key from --openai-key-file, falling back to
+// OPENAI_API_KEY.
+func openaiKey() (string, error) {
+	if openaiKeyFile != "" {
+		data, err := os.ReadFile(openaiKeyFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read openai key: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	if key := os.Getenv("OPENAI_API_KEY"); key != "" {
+		return key, nil
+	}
+	return "", errors.New("no openai key: set OPENAI_API_KEY or --openai-key-file")
+}
+
+// openaiProvider consults any service speaking the OpenAI chat completions API.
+type openaiProvider struct {
+	baseURL      string
+	apiKey       string
+	model        string
+	temperature  float64
+	systemPrompt string
+}
+
+type openaiMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openaiChatRequest struct {
+	Model       string          `json:"model"`
+	Messages    []openaiMessage `json:"messages"`
+	Temperature float64         `json:"temperature"`
+	Stream      bool            `json:"stream,omitempty"`
+}
+
+type openaiChatResponse struct {
+	Choices []struct {
+		Message      openaiMessage `json:"message"`
+		Delta        openaiMessage `json:"delta"` // Set instead of Message when streaming
+		FinishReason string        `json:"finish_reason"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+		Code    string `json:"code"`
+	} `json:"error"`
+}
+
+// Why an answer stops, or an error's code, when a content filter stopped it
+const openaiContentFilter = "content_filter"
+
+// failure describes a reply that came back with status instead of an answer.
+func (r openaiChatResponse) failure(status int) error {
+	if r.Error == nil {
+		return statusError("openai endpoint", status, "")
+	}
+	if r.Error.Code == openaiContentFilter {
+		return fmt.Errorf("openai endpoint refused the question: %s: %w", r.Error.Message, ErrModerated)
+	}
+	return statusError("openai endpoint", status, r.Error.Message)
+}
+
+// filtered reports whether a content filter cut the answer off.
+func (r openaiChatResponse) filtered() bool {
+	return len(r.Choices) > 0 && r.Choices[0].FinishReason == openaiContentFilter
+}
+
+// chat sends the question to the chat completions endpoint, asking for a
+// streamed reply or not.
+func (p *openaiProvider) chat(ctx context.Context, question string, stream bool) (*http.Response, error) {
+	prompt := systemPrompt(personaFrom(ctx))
+	if p.systemPrompt != "" {
+		prompt = p.systemPrompt + delimiterRules
+	}
+	messages := []openaiMessage{{Role: "system", Content: prompt + languageRule(question)}}
+	for _, ex := range conversationFrom(ctx) {
+		messages = append(messages,
+			openaiMessage{Role: "user", Content: wrapQuestion(ex.Question)},
+			openaiMessage{Role: "assistant", Content: ex.Answer},
+		)
+	}
+	payload := openaiChatRequest{
+		Model:       modelFrom(ctx, p.model),
+		Messages:    append(messages, openaiMessage{Role: "user", Content: wrapQuestion(question)}),
+		Temperature: p.temperature,
+		Stream:      stream,
+	}
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal question: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewBuffer(payloadBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build openai request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := sendWithRetries(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach openai endpoint: %w", err)
+	}
+	return resp, nil
+}
+
+func (p *openaiProvider) GetAnswer(ctx context.Context, question string) (string, error) {
+	resp, err := p.chat(ctx, question, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var chatResp openaiChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return "", fmt.Errorf("failed to decode openai response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", chatResp.failure(resp.StatusCode)
+	}
+	if len(chatResp.Choices) == 0 {
+		return "", fmt.Errorf("openai endpoint returned no choices")
+	}
+	if chatResp.filtered() {
+		return "", fmt.Errorf("openai endpoint's content filter stopped the answer: %w", ErrModerated)
+	}
+
+	answer := strings.TrimSpace(chatResp.Choices[0].Message.Content)
+	if answer == "" {
+		return "", fmt.Errorf("openai endpoint returned an empty answer")
+	}
+	return answer, nil
+}
+
+// StreamAnswer reads the reply as server-sent events while it's generated.
+func (p *openaiProvider) StreamAnswer(ctx context.Context, question string, onChunk func(string)) (string, error) {
+	resp, err := p.chat(ctx, question, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var chatResp openaiChatResponse
+		json.NewDecoder(resp.Body).Decode(&chatResp)
+		return "", chatResp.failure(resp.StatusCode)
+	}
+
+	var answer strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue // Blank separators, comments and other event fields
+		}
+		if data == "[DONE]" {
+			break
+		}
+		var chunk openaiChatResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return "", fmt.Errorf("failed to decode openai stream: %w", err)
+		}
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			answer.WriteString(chunk.Choices[0].Delta.Content)
+			onChunk(chunk.Choices[0].Delta.Content)
+		}
+		if chunk.filtered() {
+			return "", fmt.Errorf("openai endpoint's content filter stopped the answer: %w", ErrModerated)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read openai stream: %w", err)
+	}
+
+	result := strings.TrimSpace(answer.String())
+	if result == "" {
+		return "", fmt.Errorf("openai endpoint returned an empty answer")
+	}
+	return result, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The public wisdom API
+const defaultWisdomEndpoint = "https://orb.ponder.guru/"
+
+// The wisdom API the ponder provider asks, set by --endpoint
+var wisdomEndpoint = defaultWisdomEndpoint
+
+func init() {
+	registerProvider("ponder", func() (WisdomProvider, error) {
+		return &ponderProvider{endpoint: wisdomEndpoint}, nil
+	})
+}
+
+// JSON struct for the request payload
+type questionPayload struct {
+	Question string     `json:"question"`
+	Persona  string     `json:"persona,omitempty"`
+	Language string     `json:"language,omitempty"` // ISO 639-1 code of the question, when it can be told
+	History  []exchange `json:"history,omitempty"`  // Earlier exchanges, oldest first
+}
+
+// JSON structs for parsing the response
+type wisdomResponse struct {
+	Wisdom    string `json:"wisdom"`
+	ExpiresIn int    `json:"expires_in,omitempty"` // Seconds the wisdom stays valid
+}
+
+// Content type of a streamed answer: one wisdomChunk per line. Only sent
+// to clients that accept it, such as orb mockserver.
+const wisdomStreamType = "application/x-ndjson"
+
+// One piece of a streamed answer
+type wisdomChunk struct {
+	Wisdom    string `json:"wisdom,omitempty"`
+	Done      bool   `json:"done,omitempty"`
+	ExpiresIn int    `json:"expires_in,omitempty"` // Sent with the last chunk
+}
+
+// ponderProvider asks a wisdom API speaking the orb.ponder.guru protocol.
+type ponderProvider struct {
+	endpoint string
+}
+
+// ask sends the question, offering to take the answer streamed if stream
+// is set.
+func (p *ponderProvider) ask(ctx context.Context, question string, stream bool) (*http.Response, error) {
+	payload := questionPayload{Question: sanitizeQuestion(question), Persona: personaFrom(ctx)}
+	if lang := detectLanguage(question); lang != "und" {
+		payload.Language = lang
+	}
+	for _, ex := range conversationFrom(ctx) {
+		payload.History = append(payload.History, exchange{Question: sanitizeQuestion(ex.Question), Answer: ex.Answer})
+	}
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal question: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewBuffer(payloadBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build wisdom request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if stream {
+		req.Header.Set("Accept", wisdomStreamType+", application/json")
+	}
+	if id := requestIDFrom(ctx); id != "" {
+		req.Header.Set("X-Request-ID", id)
+		// Lets the backend recognize a retried question instead of answering it twice
+		req.Header.Set("Idempotency-Key", id)
+	}
+
+	resp, err := sendWithRetries(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wisdom: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, statusError("wisdom API", resp.StatusCode, "")
+	}
+	return resp, nil
+}
+
+func (p *ponderProvider) GetAnswer(ctx context.Context, question string) (string, error) {
+	resp, err := p.ask(ctx, question, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	return decodeWisdom(ctx, resp)
+}
+
+// StreamAnswer takes the answer a piece at a time from APIs that can send
+// it that way, and whole from those that can't.
+func (p *ponderProvider) StreamAnswer(ctx context.Context, question string, onChunk func(string)) (string, error) {
+	resp, err := p.ask(ctx, question, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), wisdomStreamType) {
+		answer, err := decodeWisdom(ctx, resp)
+		if err == nil {
+			onChunk(answer)
+		}
+		return answer, err
+	}
+
+	var answer strings.Builder
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var chunk wisdomChunk
+		if err := decoder.Decode(&chunk); err != nil {
+			return "", fmt.Errorf("failed to decode wisdom stream: %w", err)
+		}
+		if chunk.Wisdom != "" {
+			answer.WriteString(chunk.Wisdom)
+			onChunk(chunk.Wisdom)
+		}
+		if chunk.Done {
+			if chunk.ExpiresIn > 0 {
+				answerMetaFrom(ctx).expires = time.Now().Add(time.Duration(chunk.ExpiresIn) * time.Second)
+			}
+			break
+		}
+	}
+	if answer.Len() == 0 {
+		return "", fmt.Errorf("wisdom not found in response")
+	}
+	return answer.String(), nil
+}
+
+// decodeWisdom reads a whole answer from the API's response.
+func decodeWisdom(ctx context.Context, resp *http.Response) (string, error) {
+	var wisdomResp wisdomResponse
+	if err := json.NewDecoder(resp.Body).Decode(&wisdomResp); err != nil {
+		return "", fmt.Errorf("failed to decode wisdom response: %w", err)
+	}
+
+	if wisdomResp.Wisdom != "" {
+		if wisdomResp.ExpiresIn > 0 {
+			answerMetaFrom(ctx).expires = time.Now().Add(time.Duration(wisdomResp.ExpiresIn) * time.Second)
+		}
+		return wisdomResp.Wisdom, nil
+	}
+
+	return "", fmt.Errorf("wisdom not found in response")
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// runQuick opens a minimal single-question orb, meant to be bound to a
+// hotkey or launcher. The answer is printed to stdout once the UI closes;
+// if the question failed, the failure goes to stderr and it exits 1.
+func runQuick() {
+	rand.Seed(time.Now().UnixNano())
+
+	allowEndpointHosts(wisdomEndpoint)
+
+	m := initialModel()
+	m.quick = true
+	m.applyPreferences(sessionEnv(os.Environ(), acceptedEnv))
+
+	p := tea.NewProgram(m, tea.WithAltScreen())
+	final, err := p.Run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error running program: %v\n", err)
+		os.Exit(1)
+	}
+
+	fm, _ := final.(model)
+	switch {
+	case fm.retry.requestID != "":
+		// The question failed, so whatever launched the orb shouldn't take
+		// the failure words for an answer
+		if fm.answer == "" {
+			fm.answer = "The orb could not answer."
+		}
+		fmt.Fprintln(os.Stderr, fm.answer)
+		os.Exit(1)
+	case fm.answer != "":
+		fmt.Println(fm.answer)
+	}
+}
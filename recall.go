@@ -0,0 +1,103 @@
+package main
+
+import (
+	"log/slog"
+	"slices"
+)
+
+// Whether up-arrow recall reaches back into questions from earlier visits,
+// set by --recall-history
+var recallHistory = false
+
+// Most questions kept for up-arrow recall
+const recallLimit = 100
+
+// questionRecall steps through earlier questions in the prompt, like a
+// shell's history.
+type questionRecall struct {
+	questions []string // Oldest first, each only once
+	pos       int      // Index of the question shown, len(questions) for the draft
+	draft     string   // What was being typed before recalling
+	loaded    bool     // Persisted history has been merged in
+}
+
+// remember adds a question asked, moving it to the newest if it was asked before.
+func (r *questionRecall) remember(question string) {
+	r.questions = slices.DeleteFunc(r.questions, func(q string) bool { return q == question })
+	r.questions = append(r.questions, question)
+	if len(r.questions) > recallLimit {
+		r.questions = r.questions[len(r.questions)-recallLimit:]
+	}
+	r.pos, r.draft = len(r.questions), ""
+}
+
+// forget drops a question, as when it's deleted from the history.
+func (r *questionRecall) forget(question string) {
+	r.questions = slices.DeleteFunc(r.questions, func(q string) bool { return q == question })
+	r.pos = len(r.questions)
+}
+
+// load merges identity's persisted questions in before the session's own.
+func (r *questionRecall) load(identity string) {
+	r.loaded = true
+	if !recallHistory || identity == "" {
+		return
+	}
+	records, err := loadHistory(identity, recallLimit)
+	if err != nil {
+		slog.Error("loading history", "err", err)
+		return
+	}
+	session := r.questions
+	r.questions = nil
+	for i := len(records) - 1; i >= 0; i-- {
+		r.remember(records[i].Question)
+	}
+	for _, q := range session {
+		r.remember(q)
+	}
+}
+
+// older returns the question before the one shown, saving current as the
+// draft when leaving it.
+func (r *questionRecall) older(current string) (string, bool) {
+	if r.pos == 0 {
+		return "", false
+	}
+	if r.pos == len(r.questions) {
+		r.draft = current
+	}
+	r.pos--
+	return r.questions[r.pos], true
+}
+
+// newer returns the question after the one shown, or the draft past the newest.
+func (r *questionRecall) newer() (string, bool) {
+	if r.pos >= len(r.questions) {
+		return "", false
+	}
+	r.pos++
+	if r.pos == len(r.questions) {
+		return r.draft, true
+	}
+	return r.questions[r.pos], true
+}
+
+// recallQuestion puts an earlier (or later) question in the prompt.
+func (m model) recallQuestion(key string) model {
+	if !m.recall.loaded {
+		m.recall.load(m.identity)
+	}
+	var question string
+	var ok bool
+	if key == "up" {
+		question, ok = m.recall.older(m.textInput.Value())
+	} else {
+		question, ok = m.recall.newer()
+	}
+	if ok {
+		m.textInput.SetValue(question)
+		m.textInput.CursorEnd()
+	}
+	return m
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The key that hides the answer and input while the screen is shared, and
+// shows them again
+const hideKey = "ctrl+x"
+
+// obscure replaces the text of a rendered box with blocks of the same
+// shape, so it reads as covered rather than missing.
+func obscure(view string, newStyle func() lipgloss.Style) string {
+	plain := escapeSequence.ReplaceAllString(view, "")
+	var b strings.Builder
+	for _, r := range plain {
+		switch {
+		case r == '\n' || r == ' ':
+			b.WriteRune(r)
+		default:
+			b.WriteString(strings.Repeat("▓", max(lipgloss.Width(string(r)), 1)))
+		}
+	}
+	return newStyle().Foreground(lipgloss.Color("238")).Render(b.String())
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// The largest terminal the orb believes in; bigger reports are clamped
+const (
+	maxTermWidth  = 1000
+	maxTermHeight = 500
+)
+
+// How often a session's window changes reach the orb at most. A flood of
+// them settles on the latest size instead of redrawing for each.
+const resizeInterval = 100 * time.Millisecond
+
+// clampSize keeps a terminal size a client reported within reason. Sizes
+// below zero become zero, which the orb takes as unknown.
+func clampSize(width, height int) (int, int) {
+	return min(max(width, 0), maxTermWidth), min(max(height, 0), maxTermHeight)
+}
+
+// forwardResizes passes window changes on to program clamped, and at most
+// once per resizeInterval, until ctx is done.
+func forwardResizes(ctx context.Context, program *tea.Program, windowChanges <-chan ssh.Window) {
+	var latest tea.WindowSizeMsg
+	var settle <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case w := <-windowChanges:
+			latest.Width, latest.Height = clampSize(w.Width, w.Height)
+			if settle == nil {
+				settle = time.After(resizeInterval)
+			}
+		case <-settle:
+			program.Send(latest)
+			settle = nil
+		}
+	}
+}
+
+// orbSessions runs the orb for sessions with a terminal, passing on their
+// window changes with forwardResizes.
+func orbSessions() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			program := sessionProgram(s)
+			if program == nil {
+				next(s)
+				return
+			}
+			_, windowChanges, _ := s.Pty()
+			ctx, cancel := context.WithCancel(s.Context())
+			go func() {
+				forwardResizes(ctx, program, windowChanges)
+				program.Quit()
+			}()
+			if _, err := program.Run(); err != nil {
+				sessionLogger(s).Error("running the orb", "err", err)
+			}
+			// Restores the client's terminal if the orb crashed
+			program.Kill()
+			cancel()
+			next(s)
+		}
+	}
+}
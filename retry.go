@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// How often a failed provider request is tried in all, set by --retry-attempts
+var retryAttempts = 3
+
+// Backoff before the first retry, doubled for each one after and capped
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 8 * time.Second
+)
+
+// retryableStatus reports whether a response is worth asking again for.
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusBadGateway ||
+		code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// backoff returns the delay before retry n (counting from 1): exponential,
+// with full jitter so clients that failed together don't retry together.
+func backoff(n int) time.Duration {
+	d := min(retryBaseDelay<<(n-1), retryMaxDelay)
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+// sendWithRetries sends req through the outbound client, retrying transport
+// failures and overloaded backends. Retries reuse the request's headers, so
+// its Idempotency-Key keeps the backend from answering twice.
+func sendWithRetries(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		r := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+
+		resp, err := outboundClient.Do(r)
+		var reason string
+		switch {
+		case err != nil && unreachable(err) && ctx.Err() == nil:
+			reason = err.Error()
+		case err == nil && retryableStatus(resp.StatusCode):
+			reason = resp.Status
+		default:
+			return resp, err
+		}
+		if attempt >= retryAttempts || (req.Body != nil && req.GetBody == nil) {
+			if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+				resp.Body.Close()
+				return nil, &rateLimitError{host: req.URL.Host, retryAfter: retryAfter(resp, time.Now())}
+			}
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		delay := backoff(attempt)
+		loggerFrom(ctx).Warn("retrying", "host", req.URL.Host, "request", requestIDFrom(ctx), "delay", delay.Round(time.Millisecond), "reason", reason)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sleepContext waits for d, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
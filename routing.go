@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// A topic questions can be routed by, with where to send them
+type route struct {
+	Topic    string   `json:"topic"`
+	Keywords []string `json:"keywords"` // Each keyword found in the question counts towards the topic
+	Pattern  string   `json:"pattern"`  // A matching regular expression settles the topic outright
+	Provider string   `json:"provider"` // Provider (or comma separated fallback chain) to ask, empty for the default one
+	Persona  string   `json:"persona"`  // Persona to answer in, empty to keep the session's
+	Model    string   `json:"model"`    // Model for providers that run one, empty for their default
+
+	re *regexp.Regexp
+}
+
+// Score given to a route whose pattern matches, outranking any keyword count
+const patternScore = 1000
+
+// score rates how well a question fits the route's topic, 0 for not at all.
+func (r route) score(question string) int {
+	if r.re != nil && r.re.MatchString(question) {
+		return patternScore
+	}
+	lower := strings.ToLower(question)
+	score := 0
+	for _, k := range r.Keywords {
+		if strings.Contains(lower, strings.ToLower(k)) {
+			score++
+		}
+	}
+	return score
+}
+
+// routingProvider classifies each question by topic and hands it to the
+// provider and persona configured for that topic.
+type routingProvider struct {
+	routes    []route
+	providers map[string]WisdomProvider
+	fallback  WisdomProvider
+}
+
+// newRoutingProvider reads routing rules from a JSON file. Questions that
+// match no route go to fallback.
+func newRoutingProvider(path string, fallback WisdomProvider) (*routingProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read routes: %w", err)
+	}
+	var routes []route
+	if err := json.Unmarshal(data, &routes); err != nil {
+		return nil, fmt.Errorf("failed to parse routes: %w", err)
+	}
+
+	p := &routingProvider{routes: routes, providers: make(map[string]WisdomProvider), fallback: fallback}
+	for i := range p.routes {
+		r := &p.routes[i]
+		if r.Pattern != "" {
+			if r.re, err = regexp.Compile("(?i)" + r.Pattern); err != nil {
+				return nil, fmt.Errorf("route %q: %w", r.Topic, err)
+			}
+		}
+		if r.Persona != "" && !validPersona(r.Persona) {
+			return nil, fmt.Errorf("route %q: unknown persona %q", r.Topic, r.Persona)
+		}
+		if r.Provider != "" && p.providers[r.Provider] == nil {
+			provider, err := newProviderChain(r.Provider)
+			if err != nil {
+				return nil, fmt.Errorf("route %q: %w", r.Topic, err)
+			}
+			p.providers[r.Provider] = provider
+		}
+	}
+	return p, nil
+}
+
+// classify picks the best fitting route for a question, or nil.
+func (p *routingProvider) classify(question string) *route {
+	var best *route
+	bestScore := 0
+	for i := range p.routes {
+		if score := p.routes[i].score(question); score > bestScore {
+			best, bestScore = &p.routes[i], score
+		}
+	}
+	return best
+}
+
+// pick returns the provider for a question, with the route's persona and
+// model applied to ctx.
+func (p *routingProvider) pick(ctx context.Context, question string) (context.Context, WisdomProvider) {
+	r := p.classify(question)
+	if r == nil {
+		return ctx, p.fallback
+	}
+	loggerFrom(ctx).Debug("routing question", "request", requestIDFrom(ctx), "topic", r.Topic)
+	if r.Persona != "" {
+		ctx = withPersona(ctx, r.Persona)
+	}
+	if r.Model != "" {
+		ctx = withModel(ctx, r.Model)
+	}
+	if provider := p.providers[r.Provider]; provider != nil {
+		return ctx, provider
+	}
+	return ctx, p.fallback
+}
+
+func (p *routingProvider) GetAnswer(ctx context.Context, question string) (string, error) {
+	ctx, provider := p.pick(ctx, question)
+	return provider.GetAnswer(ctx, question)
+}
+
+// StreamAnswer streams when the chosen provider can; other answers arrive in
+// one chunk.
+func (p *routingProvider) StreamAnswer(ctx context.Context, question string, onChunk func(string)) (string, error) {
+	ctx, provider := p.pick(ctx, question)
+	if streaming, ok := provider.(StreamingProvider); ok {
+		return streaming.StreamAnswer(ctx, question, onChunk)
+	}
+	answer, err := provider.GetAnswer(ctx, question)
+	if err == nil {
+		onChunk(answer)
+	}
+	return answer, err
+}
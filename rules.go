@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often the rules file is checked for changes
+const rulesReloadInterval = 5 * time.Second
+
+// An operator-defined answer given without consulting the provider
+type cannedRule struct {
+	Pattern  string   `json:"pattern"`  // Regular expression matched against the question
+	Keywords []string `json:"keywords"` // Matches when every keyword appears in the question
+	Answer   string   `json:"answer"`
+
+	re *regexp.Regexp
+}
+
+// matches reports whether the rule applies to a question. Matching is case
+// insensitive.
+func (r cannedRule) matches(question string) bool {
+	if r.re != nil && r.re.MatchString(question) {
+		return true
+	}
+	if len(r.Keywords) == 0 {
+		return false
+	}
+	lower := strings.ToLower(question)
+	for _, k := range r.Keywords {
+		if !strings.Contains(lower, strings.ToLower(k)) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseRules reads a JSON list of canned rules.
+func parseRules(data []byte) ([]cannedRule, error) {
+	var rules []cannedRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse rules: %w", err)
+	}
+	for i := range rules {
+		if rules[i].Answer == "" {
+			return nil, fmt.Errorf("rule %d has no answer", i+1)
+		}
+		if rules[i].Pattern == "" && len(rules[i].Keywords) == 0 {
+			return nil, fmt.Errorf("rule %d has no pattern or keywords", i+1)
+		}
+		if rules[i].Pattern != "" {
+			re, err := regexp.Compile("(?i)" + rules[i].Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: %w", i+1, err)
+			}
+			rules[i].re = re
+		}
+	}
+	return rules, nil
+}
+
+// cannedProvider answers from the rules file when a rule matches and asks
+// the next provider otherwise.
+type cannedProvider struct {
+	path string
+	next WisdomProvider
+
+	mu       sync.RWMutex
+	rules    []cannedRule
+	modified time.Time
+}
+
+// newCannedProvider loads the rules file and keeps it fresh in the background.
+func newCannedProvider(path string, next WisdomProvider) (*cannedProvider, error) {
+	p := &cannedProvider{path: path, next: next}
+	if err := p.reload(); err != nil {
+		return nil, err
+	}
+	go p.watch(rulesReloadInterval)
+	return p, nil
+}
+
+// reload rereads the rules file if it changed since it was last read.
+func (p *cannedProvider) reload() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat rules: %w", err)
+	}
+	p.mu.RLock()
+	unchanged := info.ModTime().Equal(p.modified)
+	p.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to read rules: %w", err)
+	}
+	rules, err := parseRules(data)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.rules, p.modified = rules, info.ModTime()
+	p.mu.Unlock()
+	slog.Info("loaded canned answers", "count", len(rules), "path", p.path)
+	return nil
+}
+
+// watch polls the rules file, keeping the last good rules when an edit
+// doesn't parse.
+func (p *cannedProvider) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := p.reload(); err != nil {
+			slog.Error("reloading rules", "err", err)
+		}
+	}
+}
+
+// match returns the canned answer for a question, if a rule has one.
+func (p *cannedProvider) match(question string) (string, bool) {
+	p.mu.RLock()
+	rules := p.rules
+	p.mu.RUnlock()
+	for _, r := range rules {
+		if r.matches(question) {
+			return r.Answer, true
+		}
+	}
+	return "", false
+}
+
+func (p *cannedProvider) GetAnswer(ctx context.Context, question string) (string, error) {
+	if answer, ok := p.match(question); ok {
+		answerMetaFrom(ctx).note("a canned rule answered instead of the provider")
+		return answer, nil
+	}
+	return p.next.GetAnswer(ctx, question)
+}
+
+// StreamAnswer lets a streaming provider behind the rules keep streaming.
+// Canned answers and non-streaming providers arrive in one chunk.
+func (p *cannedProvider) StreamAnswer(ctx context.Context, question string, onChunk func(string)) (string, error) {
+	if next, ok := p.next.(StreamingProvider); ok {
+		if _, canned := p.match(question); !canned {
+			return next.StreamAnswer(ctx, question, onChunk)
+		}
+	}
+	answer, err := p.GetAnswer(ctx, question)
+	if err == nil {
+		onChunk(answer)
+	}
+	return answer, err
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Fewest answer lines shown at once, however small the orb
+const minAnswerLines = 3
+
+// answerText is as much of the answer as the typewriter has revealed.
+func (m model) answerText() string {
+	if m.thinking {
+		return m.typed(m.streamed)
+	}
+	return m.typed(m.answer)
+}
+
+// fitAnswer sizes the answer viewport to the orb and fills it with the
+// wrapped answer, which it returns.
+func (m *model) fitAnswer() string {
+	l := computeLayout(m.width, m.quick || m.inline, multiOrb)
+	width := max(l.orbWidth/2-4, 10)
+	newStyle := lipgloss.NewStyle
+	if m.renderer != nil {
+		newStyle = m.renderer.NewStyle
+	}
+	text := m.answerLayout.render(m.answerText(), width, newStyle)
+	if m.thinking {
+		// A streaming answer keeps the full width so the box doesn't jitter
+		text = lipgloss.NewStyle().Width(m.answerLayout.width(width)).Render(text)
+	}
+	m.answerPort.Width = lipgloss.Width(text)
+	m.answerPort.Height = max(l.visibleHeight/2, minAnswerLines)
+	m.answerPort.SetContent(text)
+	if m.typing {
+		// Follow the typewriter
+		m.answerPort.GotoBottom()
+	}
+	return text
+}
+
+// answerBody renders the answer, scrolling it inside the orb when it's
+// too long to fit.
+func (m model) answerBody(newStyle func() lipgloss.Style) string {
+	text := m.fitAnswer()
+	if m.answerPort.TotalLineCount() <= m.answerPort.Height {
+		return text
+	}
+	up, down := " ", " "
+	if !m.answerPort.AtTop() {
+		up = "▲"
+	}
+	if !m.answerPort.AtBottom() {
+		down = "▼"
+	}
+	indicator := newStyle().Width(m.answerPort.Width).Align(lipgloss.Center).Foreground(lipgloss.Color("240")).
+		Render(fmt.Sprintf("%s %3.f%% %s  pgup/pgdn", up, m.answerPort.ScrollPercent()*100, down))
+	return lipgloss.JoinVertical(lipgloss.Center, m.answerPort.View(), indicator)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"crypto/sha256"
+	"strings"
+)
+
+// Glyphs a seal is drawn from, one per nibble of the digest
+var sealGlyphs = []rune("ᚠᚢᚦᚨᚱᚲᚷᚹᚺᚾᛁᛃᛇᛈᛉᛊ")
+
+// Number of glyphs in a seal
+const sealLength = 8
+
+// prophecySeal derives a short sigil from a question and its answer. The
+// same pair always yields the same seal, so a shared prophecy can be
+// checked against the one recorded in the log.
+func prophecySeal(question, answer string) string {
+	sum := sha256.Sum256([]byte(question + "\n" + answer))
+
+	var b strings.Builder
+	for i := 0; i < sealLength/2; i++ {
+		b.WriteRune(sealGlyphs[sum[i]>>4])
+		b.WriteRune(sealGlyphs[sum[i]&0x0f])
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"expvar"
+	"log/slog"
+	"os"
+	"runtime"
+	"time"
+)
+
+// Self-check metrics, served under /debug/vars when --metrics-addr is set
+var (
+	metricGoroutines  = expvar.NewInt("goroutines")
+	metricOpenFDs     = expvar.NewInt("open_fds")
+	metricSessions    = expvar.NewInt("sessions")
+	metricConnections = expvar.NewInt("connections")
+)
+
+// Answer outcomes, with the latest request ID of each as an exemplar
+var (
+	metricAnswers          = expvar.NewMap("answers")
+	metricQuestions        = expvar.NewInt("questions")
+	metricLastRequest      = expvar.NewString("last_answer_request_id")
+	metricLastErrorRequest = expvar.NewString("last_error_request_id")
+)
+
+// countOpenFDs returns the number of open file descriptors, or -1 where
+// the platform doesn't expose them.
+func countOpenFDs() int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	return len(entries)
+}
+
+// runSelfChecks periodically samples resource usage so slow leaks such as
+// orphaned tickers show up in logs and metrics during soak tests. When
+// maxGoroutines is exceeded, restart is called once to recycle the process.
+// A zero interval turns the checks off.
+func runSelfChecks(interval time.Duration, maxGoroutines int, restart func()) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		goroutines := runtime.NumGoroutine()
+		fds := countOpenFDs()
+		sessions := activeSessions.count()
+		conns := int(openConns.Load())
+
+		metricGoroutines.Set(int64(goroutines))
+		metricOpenFDs.Set(int64(fds))
+		metricSessions.Set(int64(sessions))
+		metricConnections.Set(int64(conns))
+
+		slog.Info("selfcheck", "goroutines", goroutines, "fds", fds, "sessions", sessions, "connections", conns)
+		if sessions > conns {
+			slog.Warn("selfcheck: sessions outlived their connections", "count", sessions-conns)
+		}
+
+		if maxGoroutines > 0 && goroutines > maxGoroutines {
+			slog.Error("selfcheck: too many goroutines, restarting", "goroutines", goroutines, "limit", maxGoroutines)
+			restart()
+			return
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/muesli/termenv"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Most sessions served at once, set by --max-sessions; 0 for no limit
+var maxSessions = 0
+
+// A session running the orb, as the admin dashboard shows it
+type sessionInfo struct {
+	id        string
+	remote    string
+	identity  string // Key fingerprint, "" without a key
+	client    string // Who the abuse heuristics know the client as
+	web       bool   // In the browser rather than over ssh
+	started   time.Time
+	questions int
+	close     func() error // Drops the client's connection
+}
+
+// Bookkeeping of the sessions currently running the orb
+type sessionRegistry struct {
+	mu       sync.Mutex
+	sessions map[string]*sessionInfo // By session ID
+}
+
+var activeSessions = &sessionRegistry{sessions: make(map[string]*sessionInfo)}
+
+// add registers a session unless limit sessions are already open, and
+// returns how many are open.
+func (r *sessionRegistry) add(info *sessionInfo, limit int) (int, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if limit > 0 && len(r.sessions) >= limit {
+		return len(r.sessions), false
+	}
+	r.sessions[info.id] = info
+	return len(r.sessions), true
+}
+
+func (r *sessionRegistry) remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, id)
+}
+
+func (r *sessionRegistry) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.sessions)
+}
+
+// asked counts a question put to the orb in a session.
+func (r *sessionRegistry) asked(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if info, ok := r.sessions[id]; ok {
+		info.questions++
+	}
+}
+
+// list returns the open sessions, oldest first.
+func (r *sessionRegistry) list() []sessionInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]sessionInfo, 0, len(r.sessions))
+	for _, info := range r.sessions {
+		list = append(list, *info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].started.Before(list[j].started) })
+	return list
+}
+
+// disconnect drops a session's connection, reporting whether it was open.
+func (r *sessionRegistry) disconnect(id string) bool {
+	r.mu.Lock()
+	info, ok := r.sessions[id]
+	r.mu.Unlock()
+	if !ok {
+		return false
+	}
+	info.close()
+	return true
+}
+
+// trackSessions registers each session for the lifetime of its handler,
+// turning sessions away once --max-sessions are open, and bids them
+// farewell when the server is shutting down.
+func trackSessions() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			id := s.Context().SessionID()
+			info := &sessionInfo{
+				id:       id,
+				remote:   s.RemoteAddr().String(),
+				identity: keyFingerprint(s),
+				client:   clientID(s),
+				started:  time.Now(),
+				close:    s.Close,
+			}
+			if conn, ok := s.Context().Value(ssh.ContextKeyConn).(gossh.Conn); ok {
+				info.close = conn.Close
+			}
+			release, ok := trackSession(info, sessionLogger(s))
+			if !ok {
+				turnAway(s)
+				return
+			}
+			defer release()
+			next(s)
+			if serverClosing.Load() {
+				tell(s, farewell)
+			}
+		}
+	}
+}
+
+// trackSession registers a session, ssh or web, unless --max-sessions are
+// already open. The caller releases it once the session ends.
+func trackSession(info *sessionInfo, logger *slog.Logger) (release func(), ok bool) {
+	open, ok := activeSessions.add(info, maxSessions)
+	if !ok {
+		logger.Warn("too many sessions, turning one away", "open", open)
+		return nil, false
+	}
+	logger.Debug("sessions", "open", open)
+	return func() {
+		activeSessions.remove(info.id)
+		logger.Debug("sessions", "open", activeSessions.count())
+	}, true
+}
+
+// The message clients are turned away with when the orb is full
+const crowded = "The orb is crowded; come back later."
+
+// turnAway tells a client the orb is too busy to see them.
+func turnAway(s ssh.Session) {
+	tell(s, crowded)
+	s.Exit(1)
+}
+
+// tell shows a client a message outside the orb's interface: boxed in a
+// terminal, or on stderr without one.
+func tell(s ssh.Session, text string) {
+	if _, _, active := s.Pty(); !active {
+		fmt.Fprintln(s.Stderr(), text)
+		return
+	}
+	// Not the session's renderer: once the orb has run, asking the terminal
+	// about itself would race the orb's reader for the reply
+	renderer := lipgloss.NewRenderer(s)
+	renderer.SetColorProfile(termenv.ANSI256)
+	style := renderer.NewStyle().
+		Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("#626262")).
+		Foreground(lipgloss.Color("#FFF")).Padding(1, 3).Margin(1, 2)
+	// The client's terminal is raw, so lines need their carriage returns
+	fmt.Fprint(s, strings.ReplaceAll(style.Render(text), "\n", "\r\n")+"\r\n")
+}
+
+// acceptAnyKey lets clients offer a public key, so they can be recognized
+// across connections, without turning anyone away.
+func acceptAnyKey() []ssh.Option {
+	return []ssh.Option{
+		wish.WithPublicKeyAuth(func(ssh.Context, ssh.PublicKey) bool { return true }),
+		wish.WithKeyboardInteractiveAuth(func(ssh.Context, gossh.KeyboardInteractiveChallenge) bool { return true }),
+	}
+}
+
+// keyFingerprint returns the fingerprint of the public key the client proved
+// it holds, or "" when it didn't authenticate with one. Unlike the user
+// name, it can't be claimed by someone else.
+func keyFingerprint(s ssh.Session) string {
+	if key := s.PublicKey(); key != nil {
+		return gossh.FingerprintSHA256(key)
+	}
+	return ""
+}
+
+// Number of connections the ssh server currently has open
+var openConns atomic.Int64
+
+// A net.Conn that decrements openConns once when closed
+type countedConn struct {
+	net.Conn
+	once sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() { openConns.Add(-1) })
+	return c.Conn.Close()
+}
+
+// withConnCounting counts connections at the transport level, giving a view
+// of the server that is independent of our own session bookkeeping.
+func withConnCounting() ssh.Option {
+	return func(s *ssh.Server) error {
+		s.ConnCallback = func(ctx ssh.Context, conn net.Conn) net.Conn {
+			openConns.Add(1)
+			return &countedConn{Conn: conn}
+		}
+		return nil
+	}
+}
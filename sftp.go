@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+)
+
+// The parts of SFTP version 3 (draft-ietf-secsh-filexfer-02) a read-only
+// server needs
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpOpen     = 3
+	sftpClose    = 4
+	sftpRead     = 5
+	sftpWrite    = 6
+	sftpLstat    = 7
+	sftpFstat    = 8
+	sftpSetstat  = 9
+	sftpFsetstat = 10
+	sftpOpendir  = 11
+	sftpReaddir  = 12
+	sftpRemove   = 13
+	sftpMkdir    = 14
+	sftpRmdir    = 15
+	sftpRealpath = 16
+	sftpStat     = 17
+	sftpRename   = 18
+	sftpSymlink  = 20
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpData     = 103
+	sftpName     = 104
+	sftpAttrs    = 105
+
+	sftpOK               = 0
+	sftpEOF              = 1
+	sftpNoSuchFile       = 2
+	sftpPermissionDenied = 3
+	sftpFailure          = 4
+	sftpBadMessage       = 5
+	sftpUnsupported      = 8
+
+	sftpAttrSize        = 0x1
+	sftpAttrPermissions = 0x4
+	sftpAttrTimes       = 0x8
+
+	sftpOpenRead = 0x1 // Any other open flag asks to write
+)
+
+// Largest request an SFTP client may send, and the most one read returns
+const (
+	maxSFTPPacket = 64 << 10
+	maxSFTPRead   = 32 << 10
+)
+
+// Files and directories one SFTP client may hold open at once
+const maxSFTPHandles = 16
+
+// How long an SFTP client may stay quiet before it's hung up on
+const sftpIdleTimeout = 2 * time.Minute
+
+var errBadSFTPPacket = errors.New("malformed sftp packet")
+
+// transcriptSFTP serves the session key's transcripts over a read-only SFTP
+// subsystem, so sftp and newer scp clients can fetch them too:
+//
+//	sftp -P 2222 ponder.guru:transcript.txt
+func transcriptSFTP(s ssh.Session) {
+	fsys, ok := sessionTranscripts(s)
+	if !ok {
+		return
+	}
+	logger := sessionLogger(s)
+	idle := time.AfterFunc(sftpIdleTimeout, func() {
+		logger.Info("hanging up on an idle sftp client")
+		s.Close()
+	})
+	defer idle.Stop()
+
+	server := &sftpServer{fsys: fsys, handles: map[string]*sftpFile{}, out: s}
+	in := bufio.NewReader(s)
+	for {
+		packet, err := readSFTPPacket(in)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				logger.Warn("ending sftp session", "err", err)
+			}
+			break
+		}
+		idle.Reset(sftpIdleTimeout)
+		if err := server.handle(packet); err != nil {
+			logger.Warn("ending sftp session", "err", err)
+			break
+		}
+	}
+	server.closeAll()
+	s.Exit(0)
+}
+
+// readSFTPPacket reads one length-prefixed packet.
+func readSFTPPacket(r io.Reader) ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n == 0 || n > maxSFTPPacket {
+		return nil, fmt.Errorf("%w: %d bytes", errBadSFTPPacket, n)
+	}
+	packet := make([]byte, n)
+	if _, err := io.ReadFull(r, packet); err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
+
+// A file or directory an SFTP client has open
+type sftpFile struct {
+	file    fs.File
+	entries []fs.DirEntry // Left to list, for directories
+	dir     bool
+}
+
+type sftpServer struct {
+	fsys    fs.FS
+	handles map[string]*sftpFile
+	next    int
+	out     io.Writer
+}
+
+// handle answers one request. Only broken packets and failed writes end the
+// session; everything else the client is told about.
+func (v *sftpServer) handle(packet []byte) error {
+	p := sftpPacket(packet[1:])
+	if packet[0] == sftpInit {
+		// Version 3, with no extensions
+		return v.send(sftpVersion, binary.BigEndian.AppendUint32(nil, 3))
+	}
+	id, ok := p.uint32()
+	if !ok {
+		return errBadSFTPPacket
+	}
+	switch packet[0] {
+	case sftpOpen:
+		name, ok1 := p.string()
+		flags, ok2 := p.uint32()
+		if !ok1 || !ok2 {
+			return errBadSFTPPacket
+		}
+		if flags&^sftpOpenRead != 0 {
+			return v.status(id, sftpPermissionDenied, "the orb's memory is read-only")
+		}
+		return v.open(id, name, false)
+	case sftpOpendir:
+		name, ok := p.string()
+		if !ok {
+			return errBadSFTPPacket
+		}
+		return v.open(id, name, true)
+	case sftpClose:
+		handle, ok := p.string()
+		if !ok {
+			return errBadSFTPPacket
+		}
+		f, found := v.handles[handle]
+		if !found {
+			return v.status(id, sftpFailure, "no such handle")
+		}
+		f.file.Close()
+		delete(v.handles, handle)
+		return v.status(id, sftpOK, "")
+	case sftpRead:
+		handle, ok1 := p.string()
+		offset, ok2 := p.uint64()
+		length, ok3 := p.uint32()
+		if !ok1 || !ok2 || !ok3 {
+			return errBadSFTPPacket
+		}
+		return v.read(id, handle, offset, length)
+	case sftpReaddir:
+		handle, ok := p.string()
+		if !ok {
+			return errBadSFTPPacket
+		}
+		return v.readdir(id, handle)
+	case sftpStat, sftpLstat:
+		name, ok := p.string()
+		if !ok {
+			return errBadSFTPPacket
+		}
+		info, err := fs.Stat(v.fsys, fsName(name))
+		if err != nil {
+			return v.status(id, sftpNoSuchFile, "no such file")
+		}
+		return v.send(sftpAttrs, append(binary.BigEndian.AppendUint32(nil, id), sftpAttributes(info)...))
+	case sftpFstat:
+		handle, ok := p.string()
+		if !ok {
+			return errBadSFTPPacket
+		}
+		f, found := v.handles[handle]
+		if !found {
+			return v.status(id, sftpFailure, "no such handle")
+		}
+		info, err := f.file.Stat()
+		if err != nil {
+			return v.status(id, sftpFailure, err.Error())
+		}
+		return v.send(sftpAttrs, append(binary.BigEndian.AppendUint32(nil, id), sftpAttributes(info)...))
+	case sftpRealpath:
+		name, ok := p.string()
+		if !ok {
+			return errBadSFTPPacket
+		}
+		full := path.Clean("/" + name)
+		info := memInfo{name: full, dir: true}
+		if st, err := fs.Stat(v.fsys, fsName(name)); err == nil {
+			info = memInfo{name: full, size: st.Size(), dir: st.IsDir(), modTime: st.ModTime()}
+		}
+		return v.names(id, []fs.FileInfo{info})
+	case sftpWrite, sftpSetstat, sftpFsetstat, sftpRemove, sftpMkdir, sftpRmdir, sftpRename, sftpSymlink:
+		return v.status(id, sftpPermissionDenied, "the orb's memory is read-only")
+	}
+	return v.status(id, sftpUnsupported, "not supported")
+}
+
+func (v *sftpServer) open(id uint32, name string, dir bool) error {
+	if len(v.handles) >= maxSFTPHandles {
+		return v.status(id, sftpFailure, "too many open files")
+	}
+	name = fsName(name)
+	f, err := v.fsys.Open(name)
+	if err != nil {
+		return v.status(id, sftpNoSuchFile, "no such file")
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() != dir {
+		f.Close()
+		return v.status(id, sftpFailure, "wrong kind of file")
+	}
+	open := &sftpFile{file: f, dir: dir}
+	if dir {
+		if open.entries, err = fs.ReadDir(v.fsys, name); err != nil {
+			f.Close()
+			return v.status(id, sftpFailure, err.Error())
+		}
+	}
+	v.next++
+	handle := strconv.Itoa(v.next)
+	v.handles[handle] = open
+	return v.send(sftpHandle, appendSFTPString(binary.BigEndian.AppendUint32(nil, id), handle))
+}
+
+func (v *sftpServer) read(id uint32, handle string, offset uint64, length uint32) error {
+	f, found := v.handles[handle]
+	if !found || f.dir {
+		return v.status(id, sftpFailure, "no such file handle")
+	}
+	r, ok := f.file.(io.ReaderAt)
+	if !ok {
+		return v.status(id, sftpFailure, "can't read at an offset")
+	}
+	buf := make([]byte, min(length, maxSFTPRead))
+	n, err := r.ReadAt(buf, int64(min(offset, 1<<62)))
+	if n == 0 && err != nil {
+		if errors.Is(err, io.EOF) {
+			return v.status(id, sftpEOF, "")
+		}
+		return v.status(id, sftpFailure, err.Error())
+	}
+	return v.send(sftpData, appendSFTPString(binary.BigEndian.AppendUint32(nil, id), string(buf[:n])))
+}
+
+func (v *sftpServer) readdir(id uint32, handle string) error {
+	f, found := v.handles[handle]
+	if !found || !f.dir {
+		return v.status(id, sftpFailure, "no such directory handle")
+	}
+	if len(f.entries) == 0 {
+		return v.status(id, sftpEOF, "")
+	}
+	var infos []fs.FileInfo
+	for _, entry := range f.entries {
+		if info, err := entry.Info(); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	f.entries = nil
+	return v.names(id, infos)
+}
+
+// names replies with files, listed the way ls -l would.
+func (v *sftpServer) names(id uint32, infos []fs.FileInfo) error {
+	b := binary.BigEndian.AppendUint32(nil, id)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(infos)))
+	for _, info := range infos {
+		long := fmt.Sprintf("%s 1 orb orb %8d %s %s", info.Mode(), info.Size(), info.ModTime().UTC().Format("Jan _2 15:04"), path.Base(info.Name()))
+		b = appendSFTPString(b, info.Name())
+		b = appendSFTPString(b, long)
+		b = append(b, sftpAttributes(info)...)
+	}
+	return v.send(sftpName, b)
+}
+
+func (v *sftpServer) status(id uint32, code uint32, message string) error {
+	b := binary.BigEndian.AppendUint32(nil, id)
+	b = binary.BigEndian.AppendUint32(b, code)
+	b = appendSFTPString(b, message)
+	b = appendSFTPString(b, "en")
+	return v.send(sftpStatus, b)
+}
+
+func (v *sftpServer) send(kind byte, payload []byte) error {
+	b := binary.BigEndian.AppendUint32(nil, uint32(len(payload)+1))
+	b = append(b, kind)
+	_, err := v.out.Write(append(b, payload...))
+	return err
+}
+
+func (v *sftpServer) closeAll() {
+	for handle, f := range v.handles {
+		f.file.Close()
+		delete(v.handles, handle)
+	}
+}
+
+// sftpAttributes describes a file's size, permissions and times.
+func sftpAttributes(info fs.FileInfo) []byte {
+	b := binary.BigEndian.AppendUint32(nil, sftpAttrSize|sftpAttrPermissions|sftpAttrTimes)
+	b = binary.BigEndian.AppendUint64(b, uint64(info.Size()))
+	perm := uint32(info.Mode().Perm()) | 0o100000 // S_IFREG
+	if info.IsDir() {
+		perm = uint32(info.Mode().Perm()) | 0o040000 // S_IFDIR
+	}
+	b = binary.BigEndian.AppendUint32(b, perm)
+	t := uint32(max(info.ModTime().Unix(), 0))
+	b = binary.BigEndian.AppendUint32(b, t)
+	return binary.BigEndian.AppendUint32(b, t)
+}
+
+// fsName turns a client's path, absolute or not, into a name in the
+// transcripts' fs.FS.
+func fsName(name string) string {
+	name = path.Clean("/" + name)[1:]
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+func appendSFTPString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// The rest of a request, read field by field
+type sftpPacket []byte
+
+func (p *sftpPacket) uint32() (uint32, bool) {
+	if len(*p) < 4 {
+		return 0, false
+	}
+	v := binary.BigEndian.Uint32(*p)
+	*p = (*p)[4:]
+	return v, true
+}
+
+func (p *sftpPacket) uint64() (uint64, bool) {
+	if len(*p) < 8 {
+		return 0, false
+	}
+	v := binary.BigEndian.Uint64(*p)
+	*p = (*p)[8:]
+	return v, true
+}
+
+func (p *sftpPacket) string() (string, bool) {
+	n, ok := p.uint32()
+	if !ok || uint32(len(*p)) < n {
+		return "", false
+	}
+	s := string((*p)[:n])
+	*p = (*p)[n:]
+	return s, true
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+)
+
+// What sessions are told as the server shuts down
+const farewell = "The orb dims for now; come back soon."
+
+// How long open sessions get to close once the server shuts down, set by
+// --shutdown-timeout
+var shutdownTimeout = 10 * time.Second
+
+// Set once the server starts shutting down; the orb quits in every session
+var serverClosing atomic.Bool
+
+// shutdownServer stops accepting connections and asks open sessions to
+// close, cutting off any still open after shutdownTimeout.
+func shutdownServer(s *ssh.Server) {
+	serverClosing.Store(true)
+	slog.Info("shutting down", "sessions", activeSessions.count())
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		slog.Warn("cutting off sessions still open", "err", err)
+		s.Close()
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+)
+
+// Key used to sign answers, nil unless --signing-key is given
+var answerSigner ed25519.PrivateKey
+
+// signedMessage is the exact byte sequence covered by an answer signature.
+func signedMessage(question, answer string) []byte {
+	return []byte(question + "\n" + answer)
+}
+
+// signAnswer returns a base64 signature for the pair, or "" when signing is off.
+func signAnswer(question, answer string) string {
+	if answerSigner == nil {
+		return ""
+	}
+	sig := ed25519.Sign(answerSigner, signedMessage(question, answer))
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+// verifyAnswer reports whether signature is pub's signature of the pair.
+func verifyAnswer(pub ed25519.PublicKey, question, answer, signature string) (bool, error) {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	return ed25519.Verify(pub, signedMessage(question, answer), sig), nil
+}
+
+// verifyRequest is the body accepted by the /verify endpoint.
+type verifyRequest struct {
+	Question  string `json:"question"`
+	Answer    string `json:"answer"`
+	Signature string `json:"signature"`
+}
+
+// handleVerify lets recipients of a shared card check it against this orb's key.
+func handleVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST a JSON body with question, answer and signature", http.StatusMethodNotAllowed)
+		return
+	}
+	var req verifyRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	valid, err := verifyAnswer(answerSigner.Public().(ed25519.PublicKey), req.Question, req.Answer, req.Signature)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"valid": valid})
+}
+
+// loadSigningKey reads a PKCS#8 PEM ed25519 key, generating one (and its
+// public half next to it as path+".pub") if the file doesn't exist yet.
+func loadSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return generateSigningKey(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing key %s is not PEM encoded", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s is not an ed25519 key", path)
+	}
+	return edKey, nil
+}
+
+func generateSigningKey(path string) (ed25519.PrivateKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signing key: %w", err)
+	}
+	privPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER})
+	if err := os.WriteFile(path, privPEM, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write signing key: %w", err)
+	}
+
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key: %w", err)
+	}
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+	if err := os.WriteFile(path+".pub", pubPEM, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write public key: %w", err)
+	}
+	return priv, nil
+}
+
+func loadPublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("public key %s is not PEM encoded", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key %s is not an ed25519 key", path)
+	}
+	return edKey, nil
+}
+
+// runVerify checks that a shared answer was signed by the given orb's key.
+func runVerify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	keyPath := flags.String("key", "", "public key of the orb (PEM)")
+	question := flags.String("question", "", "the question that was asked")
+	answer := flags.String("answer", "", "the answer as shared")
+	signature := flags.String("signature", "", "base64 signature that came with the answer")
+	flags.Parse(args)
+
+	if *keyPath == "" || *signature == "" {
+		fmt.Fprintln(os.Stderr, "usage: orb verify --key orb.pub --question Q --answer A --signature SIG")
+		os.Exit(2)
+	}
+
+	pub, err := loadPublicKey(*keyPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	valid, err := verifyAnswer(pub, *question, *answer, *signature)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if !valid {
+		fmt.Println("invalid: this prophecy did not come from this orb")
+		os.Exit(1)
+	}
+	fmt.Println("valid: the orb has spoken")
+}
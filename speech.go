@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Typed into the prompt to dictate a question instead
+const speakCommand = "/speak"
+
+// A message with the transcription of a spoken question
+type transcriptionMsg struct {
+	text string
+	err  error
+}
+
+// listenCmd runs the configured speech-to-text command, which is expected
+// to record until silence and print the transcription to stdout.
+func listenCmd(command string) tea.Cmd {
+	return func() tea.Msg {
+		out, err := exec.Command("sh", "-c", command).Output()
+		if err != nil {
+			return transcriptionMsg{err: fmt.Errorf("speech command failed: %w", err)}
+		}
+		return transcriptionMsg{text: strings.TrimSpace(string(out))}
+	}
+}
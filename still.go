@@ -0,0 +1,33 @@
+package main
+
+import (
+	"time"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Whether sessions start with a still orb, set by --no-animation
+var noAnimation = false
+
+// The key that stills the orb, and sets it moving again
+const stillKey = "ctrl+s"
+
+// How often a still orb wakes to check its timers, redrawing only then or
+// when something happens
+const stillTickInterval = time.Second
+
+// toggleStill stops the orb's animation, and with it the spinner and the
+// blinking cursor, or starts them all again.
+func (m model) toggleStill() (tea.Model, tea.Cmd) {
+	m.still = !m.still
+	if m.still {
+		usage.feature("still")
+		return m, m.textInput.Cursor.SetMode(cursor.CursorStatic)
+	}
+	cmds := []tea.Cmd{m.textInput.Cursor.SetMode(cursor.CursorBlink)}
+	if m.thinking || m.listening {
+		cmds = append(cmds, m.spinner.Tick)
+	}
+	return m, tea.Batch(cmds...)
+}
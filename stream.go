@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// StreamingProvider is a WisdomProvider that can hand over an answer while
+// it's still being written. onChunk is called with each new piece of text;
+// the whole answer is returned at the end.
+type StreamingProvider interface {
+	WisdomProvider
+	StreamAnswer(ctx context.Context, question string, onChunk func(string)) (string, error)
+}
+
+// Runes of a streamed answer revealed per animation frame, at least
+const typewriterRunesPerTick = 2
+
+// Share of the streamed text not yet shown that the typewriter catches up
+// on each frame, so it doesn't fall far behind a fast provider
+const typewriterCatchUp = 0.15
+
+// Spinner colors as it fades out once the answer starts arriving
+var spinnerFade = []lipgloss.Color{"155", "149", "107", "65", "59", "238"}
+
+// Frames the spinner spends on each color of its fade, on average
+const spinnerFadeFrames = 4
+
+// A piece of an answer that is still arriving
+type streamChunkMsg struct {
+	text string
+	next tea.Cmd // Waits for the next chunk or the final answer
+}
+
+// An answer being streamed by a provider. Chunks collect here so the
+// provider never waits on the UI.
+type answerStream struct {
+	mu      sync.Mutex
+	text    string
+	final   tea.Msg // answerMsg or errMsg once the stream has finished
+	updated chan struct{}
+}
+
+// streamAnswerCmd starts streaming an answer; finish turns the provider's
+// result into the final message.
+func streamAnswerCmd(ctx context.Context, p StreamingProvider, question string, finish func(string, error) tea.Msg) tea.Cmd {
+	s := &answerStream{updated: make(chan struct{}, 1)}
+	go func() {
+		answer, err := p.StreamAnswer(ctx, question, func(chunk string) {
+			s.mu.Lock()
+			s.text += chunk
+			s.mu.Unlock()
+			s.notify()
+		})
+		msg := finish(answer, err)
+		s.mu.Lock()
+		s.final = msg
+		s.mu.Unlock()
+		s.notify()
+	}()
+	return s.next(0)
+}
+
+func (s *answerStream) notify() {
+	select {
+	case s.updated <- struct{}{}:
+	default:
+	}
+}
+
+// next waits for text past what has been delivered, then for the final message.
+func (s *answerStream) next(delivered int) tea.Cmd {
+	return func() tea.Msg {
+		for {
+			s.mu.Lock()
+			text, final := s.text, s.final
+			s.mu.Unlock()
+			if len(text) > delivered {
+				return streamChunkMsg{text: text[delivered:], next: s.next(len(text))}
+			}
+			if final != nil {
+				return final
+			}
+			<-s.updated
+		}
+	}
+}
+
+// typed returns as much of text as the typewriter has revealed so far.
+func (m model) typed(text string) string {
+	if !m.typing || m.still || m.revealed >= utf8.RuneCountInString(text) {
+		return text
+	}
+	return string([]rune(text)[:m.revealed])
+}
+
+// spinnerFaded returns the spinner's color a number of frames into the
+// stream, and false once it has faded out completely.
+func spinnerFaded(frames int) (lipgloss.Color, bool) {
+	t := float64(frames) / float64(spinnerFadeFrames*len(spinnerFade))
+	step := int(ease(t) * float64(len(spinnerFade)))
+	if t >= 1 || step >= len(spinnerFade) {
+		return "", false
+	}
+	return spinnerFade[max(step, 0)], true
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Where anonymous usage counts are reported, set by --telemetry. Empty, the
+// default, reports nothing.
+var telemetryEndpoint = ""
+
+// How often usage counts are reported
+const telemetryInterval = time.Hour
+
+// Usage since the last report. Only totals are kept: no questions, answers,
+// users or addresses.
+type usageCounts struct {
+	mu        sync.Mutex
+	since     time.Time
+	sessions  int64
+	questions int64
+	features  map[string]int64
+}
+
+var usage = &usageCounts{since: time.Now(), features: make(map[string]int64)}
+
+func (u *usageCounts) session() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.sessions++
+}
+
+func (u *usageCounts) question() {
+	metricQuestions.Add(1) // Kept for the banner, whether or not usage is reported
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.questions++
+}
+
+// feature counts a use of one of the orb's features, such as "focus".
+func (u *usageCounts) feature(name string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.features[name]++
+}
+
+// What a telemetry report sends, and all it sends
+type telemetryReport struct {
+	Version       string           `json:"version"`
+	GoVersion     string           `json:"go"`
+	OS            string           `json:"os"`
+	Arch          string           `json:"arch"`
+	PeriodSeconds int64            `json:"period_seconds"`
+	Sessions      int64            `json:"sessions"`
+	Questions     int64            `json:"questions"`
+	Features      map[string]int64 `json:"features,omitempty"`
+}
+
+// take returns the counts so far and starts counting afresh. It reports
+// false when nothing was used, so idle servers stay quiet.
+func (u *usageCounts) take(now time.Time) (telemetryReport, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	version, _ := buildVersion()
+	report := telemetryReport{
+		Version:       version,
+		GoVersion:     runtime.Version(),
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		PeriodSeconds: int64(now.Sub(u.since).Seconds()),
+		Sessions:      u.sessions,
+		Questions:     u.questions,
+		Features:      u.features,
+	}
+	u.since, u.sessions, u.questions, u.features = now, 0, 0, make(map[string]int64)
+	return report, report.Sessions > 0 || report.Questions > 0 || len(report.Features) > 0
+}
+
+// sendTelemetry reports the counts since the last report. Counts from a
+// report that fails are dropped rather than piling up.
+func sendTelemetry(ctx context.Context) error {
+	report, ok := usage.take(time.Now())
+	if !ok {
+		return nil
+	}
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode telemetry: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, telemetryEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create telemetry request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := outboundClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send telemetry: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("telemetry endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// runTelemetry reports usage counts every telemetryInterval.
+func runTelemetry() {
+	ticker := time.NewTicker(telemetryInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := sendTelemetry(context.Background()); err != nil {
+			slog.Error("reporting telemetry", "err", err)
+		}
+	}
+}
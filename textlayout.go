@@ -0,0 +1,148 @@
+package main
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Narrowest answer width a client can ask for
+const minAnswerWidth = 20
+
+// Most blank lines a client can ask for between paragraphs
+const maxParagraphSpacing = 3
+
+// Styles without color or emphasis, for text written to files and pagers
+var plainStyle = lipgloss.NewRenderer(io.Discard).NewStyle
+
+// How a client likes answers laid out, from ORB_WIDTH, ORB_ALIGN and
+// ORB_SPACING. The answer box, the history browser and exported transcripts
+// all lay answers out with it.
+type textLayout struct {
+	maxWidth int               // Widest an answer is drawn, 0 for as wide as fits
+	align    lipgloss.Position // lipgloss.Left or lipgloss.Center
+	spacing  int               // Blank lines between paragraphs, negative to keep the answer's own
+}
+
+var defaultTextLayout = textLayout{align: lipgloss.Left, spacing: -1}
+
+// apply picks up the layout preferences in a client's environment,
+// ignoring values it can't use.
+func (t textLayout) apply(env map[string]string) textLayout {
+	if w, err := strconv.Atoi(env["ORB_WIDTH"]); err == nil && w >= minAnswerWidth {
+		t.maxWidth = w
+	}
+	switch strings.ToLower(env["ORB_ALIGN"]) {
+	case "left":
+		t.align = lipgloss.Left
+	case "center", "centre":
+		t.align = lipgloss.Center
+	}
+	if n, err := strconv.Atoi(env["ORB_SPACING"]); err == nil && n >= 0 && n <= maxParagraphSpacing {
+		t.spacing = n
+	}
+	return t
+}
+
+// width is how wide to lay text out with room for avail cells.
+func (t textLayout) width(avail int) int {
+	if t.maxWidth > 0 {
+		return min(avail, t.maxWidth)
+	}
+	return avail
+}
+
+// render lays text out at most width cells wide, as markdown when it looks
+// like markdown, and otherwise wrapped by the rules of its script.
+func (t textLayout) render(text string, width int, newStyle func() lipgloss.Style) string {
+	width = t.width(width)
+	if looksLikeMarkdown(text) && !hasRTL(text) {
+		text = renderMarkdown(text, width, newStyle)
+	} else if writtenWithoutSpaces(detectLanguage(text)) {
+		text = wrapCharacters(text, width)
+	} else {
+		text = bidiText(text, width)
+	}
+	text = t.space(text)
+	if lipgloss.Width(text) > width {
+		text = newStyle().Width(width).Render(text)
+	}
+	if t.align != lipgloss.Left {
+		// Wrapping pads lines out to the width; center what's on them
+		text = newStyle().Align(t.align).Render(trimLines(text))
+	}
+	return text
+}
+
+// export lays text out for a file or pager. Without a preferred width it's
+// left for the reader to wrap.
+func (t textLayout) export(text string) string {
+	if t.maxWidth == 0 {
+		return t.space(text)
+	}
+	return trimLines(t.render(text, t.maxWidth, plainStyle))
+}
+
+// trimLines drops the spaces at the end of each line.
+func trimLines(text string) string {
+	lines := strings.Split(text, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Punctuation and small kana that mustn't begin a line of Chinese or
+// Japanese
+const noLineStart = "、。，．・：；！？）」』】〕〉》…ー々ぁぃぅぇぉっゃゅょァィゥェォッャュョ"
+
+// wrapCharacters breaks text written without spaces between any two
+// characters, as wide as fits, carrying a character down with punctuation
+// that mustn't begin a line.
+func wrapCharacters(text string, width int) string {
+	var out []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		var line []string
+		lineWidth := 0
+		for _, c := range graphemes(paragraph) {
+			w := lipgloss.Width(c)
+			if lineWidth+w > width && len(line) > 0 {
+				var carried []string
+				if strings.Contains(noLineStart, c) && len(line) > 1 {
+					line, carried = line[:len(line)-1], line[len(line)-1:]
+				}
+				out = append(out, strings.Join(line, ""))
+				line, lineWidth = carried, lipgloss.Width(strings.Join(carried, ""))
+			}
+			line = append(line, c)
+			lineWidth += w
+		}
+		out = append(out, strings.Join(line, ""))
+	}
+	return strings.Join(out, "\n")
+}
+
+// space puts the preferred number of blank lines between paragraphs.
+func (t textLayout) space(text string) string {
+	if t.spacing < 0 {
+		return text
+	}
+	var out []string
+	blank := false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			blank = true
+			continue
+		}
+		if blank && len(out) > 0 {
+			for range t.spacing {
+				out = append(out, "")
+			}
+		}
+		blank = false
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// A named color scheme for the orb and header
+type theme struct {
+	hueStart   float64          // First hue of the sweep
+	hueSpan    float64          // Width of the sweep, 360 cycles the whole wheel
+	saturation float64          // Saturation of the swirl colors, 0 to 100
+	speed      float64          // How fast the orb swirls, 1 is the classic pace
+	rim        lipgloss.Color   // Color of the orb's outer edge
+	colors     []lipgloss.Color // Five fixed swirl colors used instead of the hue sweep, if set
+}
+
+// Built-in themes, selectable with --theme, per session with ORB_THEME and
+// live with themeKey
+var themes = map[string]theme{
+	"cosmic":     {hueStart: 0, hueSpan: 360, saturation: 65, speed: 1, rim: darkestBlue},
+	"fire":       {hueStart: 0, hueSpan: 45, saturation: 65, speed: 1, rim: lipgloss.Color("#2A0800")},
+	"sea":        {hueStart: 170, hueSpan: 60, saturation: 65, speed: 1, rim: lipgloss.Color("#00202A")},
+	"emerald":    {hueStart: 120, hueSpan: 40, saturation: 60, speed: 0.8, rim: lipgloss.Color("#00220F")},
+	"ember":      {hueStart: 5, hueSpan: 25, saturation: 80, speed: 0.5, rim: lipgloss.Color("#180400")},
+	"monochrome": {hueStart: 0, hueSpan: 0, saturation: 0, speed: 1, rim: lipgloss.Color("#1C1C1C")},
+	// The Okabe-Ito colors, told apart with any kind of color blindness
+	"colorblind": {speed: 1, rim: lipgloss.Color("#000000"), colors: []lipgloss.Color{
+		"#0072B2", "#56B4E9", "#F0E442", "#E69F00", "#D55E00",
+	}},
+}
+
+// The key that switches to the next theme
+const themeKey = "ctrl+t"
+
+// Themes loaded from or saved to the themes directory
+var (
+	customThemes   = make(map[string]theme)
+	customThemesMu sync.RWMutex
+)
+
+const defaultTheme = "cosmic"
+
+// Theme sessions start with, set by --theme
+var orbTheme = defaultTheme
+
+// Directory theme files are loaded from and saved to, set by --themes-dir;
+// empty disables saving themes from the editor
+var themesDir = ""
+
+// Names theme files may be saved under
+var themeNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// baseHue returns the hue the palettes are built from for the given frame.
+// Full-wheel themes rotate forever; narrow themes drift back and forth.
+func (t theme) baseHue(frame int) float64 {
+	if t.hueSpan >= 360 {
+		return math.Mod(t.hueStart+float64(frame)/3.0, 360)
+	}
+	return t.hueStart + t.hueSpan/2*(1+math.Sin(float64(frame)/60.0))
+}
+
+// startTheme returns the configured starting theme and its name, or the
+// default if it doesn't exist.
+func startTheme() (theme, string) {
+	if t, ok := lookupTheme(orbTheme); ok {
+		return t, orbTheme
+	}
+	return themes[defaultTheme], defaultTheme
+}
+
+// How long the orb crossfades from one theme's colors into the next
+const themeTransition = time.Second
+
+// The colors the orb is drawn in at a moment
+type orbColors struct {
+	palette []lipgloss.Color // Swirl colors, as theme.palette builds them
+	header  []lipgloss.Color // Gradient the header is painted with
+	rim     lipgloss.Color
+}
+
+// Colors the orb was drawn in when its theme last changed
+type themeShift struct {
+	from    orbColors
+	started time.Time
+}
+
+// setTheme changes the theme, crossfading from the colors on screen.
+func (m *model) setTheme(t theme) {
+	now := time.Now()
+	m.themeShift = themeShift{from: m.colors(now), started: now}
+	m.theme = t
+}
+
+// colors returns the theme's colors for the current frame, tinted by the
+// mood (or dulled in low-power mode) and blended with the last theme's while the crossfade runs.
+func (m model) colors(now time.Time) orbColors {
+	baseHue := m.theme.baseHue(m.theme.swirlFrame(ambientFrame(m.frame)))
+	if !lowPower {
+		baseHue = m.mood.apply(baseHue, now)
+	}
+	c := orbColors{
+		palette: m.theme.palette(baseHue),
+		header:  m.theme.header(baseHue),
+		rim:     m.theme.rim,
+	}
+	if lowPower {
+		c.mute(lowPowerSaturation)
+	}
+	if m.themeShift.started.IsZero() {
+		return c
+	}
+	t := progress(m.themeShift.started, themeTransition, now)
+	if t >= 1 {
+		return c
+	}
+	t = ease(t)
+	from := m.themeShift.from
+	for i := range c.palette {
+		c.palette[i] = blendColor(from.palette[i], c.palette[i], t)
+	}
+	for i := range c.header {
+		c.header[i] = blendColor(from.header[i], c.header[i], t)
+	}
+	c.rim = blendColor(from.rim, c.rim, t)
+	return c
+}
+
+// blendColor mixes two #rrggbb colors, t of the way from a to b. Colors
+// that aren't hex switch over halfway.
+func blendColor(a, b lipgloss.Color, t float64) lipgloss.Color {
+	ar, ag, ab, okA := hexRGB(string(a))
+	br, bg, bb, okB := hexRGB(string(b))
+	if !okA || !okB {
+		if t < 0.5 {
+			return a
+		}
+		return b
+	}
+	mix := func(x, y uint8) uint8 {
+		return uint8(math.Round(min(max(lerp(float64(x), float64(y), t), 0), 255)))
+	}
+	return lipgloss.Color(fmt.Sprintf("#%02X%02X%02X", mix(ar, br), mix(ag, bg), mix(ab, bb)))
+}
+
+// hexRGB reads a #rrggbb color.
+func hexRGB(hex string) (r, g, b uint8, ok bool) {
+	if len(hex) != 7 || hex[0] != '#' {
+		return 0, 0, 0, false
+	}
+	v, err := strconv.ParseUint(hex[1:], 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return uint8(v >> 16), uint8(v >> 8), uint8(v), true
+}
+
+// palette builds the five swirl colors around a base hue.
+func (t theme) palette(baseHue float64) []lipgloss.Color {
+	if len(t.colors) > 0 {
+		return slices.Clone(t.colors)
+	}
+	palette := make([]lipgloss.Color, 5)
+	for i := 0; i < 5; i++ {
+		hue := baseHue + float64(i)*15
+		sat := t.saturation
+		if sat > 0 { // Greys stay grey
+			sat = math.Min(sat+float64(i)*3, 100)
+		}
+		light := 65.0 - float64(i)*2
+		palette[i] = lipgloss.Color(hslToHex(hue, sat, light))
+	}
+	return palette
+}
+
+// header builds the header's ten gradient colors from a base hue.
+func (t theme) header(baseHue float64) []lipgloss.Color {
+	gradient := make([]lipgloss.Color, 10)
+	if len(t.colors) > 0 {
+		// Blend along the fixed colors instead
+		for i := range gradient {
+			pos := float64(i) / float64(len(gradient)-1) * float64(len(t.colors)-1)
+			j := min(int(pos), len(t.colors)-2)
+			gradient[i] = blendColor(t.colors[j], t.colors[j+1], pos-float64(j))
+		}
+		return gradient
+	}
+	for i := 0; i < 10; i++ {
+		hue := baseHue + float64(i)*10
+		sat := 70.0
+		if t.saturation == 0 {
+			sat = 0
+		}
+		light := 65.0
+		gradient[i] = lipgloss.Color(hslToHex(hue, sat, light))
+	}
+	return gradient
+}
+
+// themeNames lists the built-in and custom themes, in the order themeKey
+// steps through them.
+func themeNames() []string {
+	customThemesMu.RLock()
+	defer customThemesMu.RUnlock()
+	names := slices.Collect(maps.Keys(themes))
+	for name := range customThemes {
+		if _, builtin := themes[name]; !builtin {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// nextTheme switches to the theme after the current one.
+func (m *model) nextTheme() {
+	names := themeNames()
+	i := (slices.Index(names, m.themeName) + 1) % len(names)
+	t, _ := lookupTheme(names[i])
+	m.themeName = names[i]
+	m.setTheme(t)
+}
+
+// swirlFrame scales the animation frame by the theme's speed.
+func (t theme) swirlFrame(frame int) int {
+	return int(float64(frame) * t.speed)
+}
+
+// lookupTheme finds a built-in or custom theme by name.
+func lookupTheme(name string) (theme, bool) {
+	if t, ok := themes[name]; ok {
+		return t, true
+	}
+	customThemesMu.RLock()
+	defer customThemesMu.RUnlock()
+	t, ok := customThemes[name]
+	return t, ok
+}
+
+// A theme as stored on disk
+type themeFile struct {
+	HueStart   float64  `json:"hue_start"`
+	HueSpan    float64  `json:"hue_span"`
+	Saturation float64  `json:"saturation"`
+	Speed      float64  `json:"speed"`
+	Rim        string   `json:"rim"`
+	Colors     []string `json:"colors,omitempty"` // Five fixed swirl colors, replacing the hue sweep
+}
+
+// loadThemes adds every *.json theme in dir to the selectable themes.
+// Built-in themes can't be replaced.
+func loadThemes(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("failed to list themes: %w", err)
+	}
+	customThemesMu.Lock()
+	defer customThemesMu.Unlock()
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		if _, builtin := themes[name]; builtin || !themeNamePattern.MatchString(name) {
+			slog.Warn("skipping theme file", "path", path)
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read theme %s: %w", name, err)
+		}
+		var f themeFile
+		if err := json.Unmarshal(data, &f); err != nil {
+			return fmt.Errorf("failed to parse theme %s: %w", name, err)
+		}
+		if f.Speed <= 0 {
+			f.Speed = 1
+		}
+		if len(f.Colors) != 0 && len(f.Colors) != 5 {
+			return fmt.Errorf("theme %s has %d colors, want 5", name, len(f.Colors))
+		}
+		var colors []lipgloss.Color
+		for _, c := range f.Colors {
+			colors = append(colors, lipgloss.Color(c))
+		}
+		customThemes[name] = theme{hueStart: f.HueStart, hueSpan: f.HueSpan, saturation: f.Saturation, speed: f.Speed, rim: lipgloss.Color(f.Rim), colors: colors}
+	}
+	return nil
+}
+
+// saveTheme writes a theme to the themes directory and makes it selectable.
+func saveTheme(name string, t theme) error {
+	if themesDir == "" {
+		return errors.New("saving themes is disabled on this orb")
+	}
+	if !themeNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid theme name %q", name)
+	}
+	if _, builtin := themes[name]; builtin {
+		return fmt.Errorf("can't replace built-in theme %q", name)
+	}
+	f := themeFile{
+		HueStart:   t.hueStart,
+		HueSpan:    t.hueSpan,
+		Saturation: t.saturation,
+		Speed:      t.speed,
+		Rim:        string(t.rim),
+	}
+	for _, c := range t.colors {
+		f.Colors = append(f.Colors, string(c))
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode theme: %w", err)
+	}
+	if err := os.MkdirAll(themesDir, 0755); err != nil {
+		return fmt.Errorf("failed to create themes directory: %w", err)
+	}
+	customThemesMu.Lock()
+	defer customThemesMu.Unlock()
+	if err := os.WriteFile(filepath.Join(themesDir, name+".json"), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to save theme: %w", err)
+	}
+	customThemes[name] = t
+	return nil
+}
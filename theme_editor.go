@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The command that opens the theme editor
+const themeCommand = "/theme"
+
+// One adjustable knob of the theme editor
+type themeKnob struct {
+	label string
+	step  float64
+	min   float64
+	max   float64
+	wrap  bool // Hues wrap around instead of stopping at the ends
+}
+
+var themeKnobs = []themeKnob{
+	{label: "hue", step: 5, min: 0, max: 360, wrap: true},
+	{label: "hue range", step: 5, min: 0, max: 360},
+	{label: "saturation", step: 5, min: 0, max: 100},
+	{label: "swirl speed", step: 0.1, min: 0.1, max: 5},
+	{label: "rim hue", step: 5, min: 0, max: 360, wrap: true},
+}
+
+// A running /theme editing session, previewed live on the orb
+type themeEditor struct {
+	name     string
+	original theme // Restored if the edit is abandoned
+	knob     int
+	rimHue   float64
+}
+
+// parseTheme reads "/theme [name]"; the name is what the result is saved as.
+func parseTheme(input string, current theme) (themeEditor, error) {
+	name := strings.TrimSpace(strings.TrimPrefix(input, themeCommand))
+	if name == "" {
+		name = "custom"
+	}
+	if !themeNamePattern.MatchString(name) {
+		return themeEditor{}, fmt.Errorf("theme names use a-z, 0-9 and dashes")
+	}
+	return themeEditor{name: name, original: current, rimHue: current.hueStart}, nil
+}
+
+// move selects the previous or next knob.
+func (e *themeEditor) move(delta int) {
+	e.knob = (e.knob + delta + len(themeKnobs)) % len(themeKnobs)
+}
+
+// adjust turns the selected knob by steps and returns the updated theme.
+func (e *themeEditor) adjust(t theme, steps float64) theme {
+	k := themeKnobs[e.knob]
+	value := e.value(t) + steps*k.step
+	if k.wrap {
+		value = math.Mod(value+k.max, k.max)
+	} else {
+		value = math.Max(k.min, math.Min(k.max, value))
+	}
+	if e.knob <= 2 {
+		t.colors = nil // The hue sweep takes over from fixed colors
+	}
+	switch e.knob {
+	case 0:
+		t.hueStart = value
+	case 1:
+		t.hueSpan = value
+	case 2:
+		t.saturation = value
+	case 3:
+		t.speed = value
+	case 4:
+		e.rimHue = value
+		t.rim = lipgloss.Color(hslToHex(value, 100, 10))
+	}
+	return t
+}
+
+func (e *themeEditor) value(t theme) float64 {
+	switch e.knob {
+	case 0:
+		return t.hueStart
+	case 1:
+		return t.hueSpan
+	case 2:
+		return t.saturation
+	case 3:
+		return t.speed
+	default:
+		return e.rimHue
+	}
+}
+
+func (e themeEditor) status(t theme) string {
+	values := []string{
+		fmt.Sprintf("%.0f°", t.hueStart),
+		fmt.Sprintf("%.0f°", t.hueSpan),
+		fmt.Sprintf("%.0f%%", t.saturation),
+		fmt.Sprintf("%.1fx", t.speed),
+		fmt.Sprintf("%.0f°", e.rimHue),
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "theme %q\n\n", e.name)
+	for i, k := range themeKnobs {
+		cursor := "  "
+		if i == e.knob {
+			cursor = "▸ "
+		}
+		fmt.Fprintf(&b, "%s%-12s %s\n", cursor, k.label, values[i])
+	}
+	b.WriteString("\n↑/↓ choose · ←/→ adjust · enter save · esc cancel")
+	return b.String()
+}
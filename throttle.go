@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/ssh"
+)
+
+// How often a session's frame rate is fitted to its link
+const throttleInterval = time.Second
+
+// The fewest frames per second a slow link is sent before the animation
+// pauses altogether
+const minSessionFPS = 2
+
+// How long a paused animation waits before trying the link again
+const throttlePause = 5 * time.Second
+
+// outputMeter times the writes to a session's terminal. Writes block once
+// the client stops taking data, so slow ones mean the link can't keep up.
+type outputMeter struct {
+	w       io.Writer
+	mu      sync.Mutex
+	latency time.Duration // Moving average of the recent writes
+}
+
+func (o *outputMeter) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := o.w.Write(p)
+	took := time.Since(start)
+	o.mu.Lock()
+	o.latency += (took - o.latency) / 4
+	o.mu.Unlock()
+	return n, err
+}
+
+// writeLatency is how long writes have been taking lately.
+func (o *outputMeter) writeLatency() time.Duration {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.latency
+}
+
+// A session's frame rate, fitted to how fast its link takes frames
+type frameThrottle struct {
+	meter   *outputMeter
+	fps     int // 0 while the animation is paused
+	checked time.Time
+}
+
+// adjust halves the frame rate when frames take more than half their time
+// to write, and wins it back a frame at a time once they're quick again.
+// It reports whether the rate changed.
+func (t *frameThrottle) adjust(now time.Time) bool {
+	if t.fps == 0 {
+		if now.Sub(t.checked) < throttlePause {
+			return false
+		}
+		// See whether the link has recovered
+		t.fps, t.checked = minSessionFPS, now
+		return true
+	}
+	if now.Sub(t.checked) < throttleInterval {
+		return false
+	}
+	t.checked = now
+	latency, budget := t.meter.writeLatency(), time.Second/time.Duration(t.fps)
+	fps := t.fps
+	switch {
+	case latency > budget/2 && fps <= minSessionFPS:
+		fps = 0
+	case latency > budget/2:
+		fps = max(fps/2, minSessionFPS)
+	case latency < budget/8:
+		fps = min(fps+1, frameRate())
+	}
+	changed := fps != t.fps
+	t.fps = fps
+	return changed
+}
+
+// frameInterval is how long the session waits between ticks.
+func (m model) frameInterval() time.Duration {
+	switch {
+	case m.still || (m.throttle != nil && m.throttle.fps == 0):
+		return stillTickInterval
+	case m.throttle != nil:
+		return time.Second / time.Duration(m.throttle.fps)
+	}
+	return time.Second / time.Duration(frameRate())
+}
+
+// animating reports whether the orb moves on each tick.
+func (m model) animating() bool {
+	return !m.still && (m.throttle == nil || m.throttle.fps > 0)
+}
+
+// sessionProgram runs the orb for an ssh session, metering what it writes
+// so the frame rate can follow the link.
+func sessionProgram(s ssh.Session) *tea.Program {
+	orb, opts := teaHandler(s)
+	m, ok := orb.(model)
+	if !ok {
+		return nil
+	}
+	// The server emulates ptys, so the session itself is the terminal
+	meter := &outputMeter{w: s}
+	m.throttle = &frameThrottle{meter: meter, fps: frameRate(), checked: time.Now()}
+	return tea.NewProgram(m, append(opts, tea.WithInput(s), tea.WithOutput(meter))...)
+}
@@ -0,0 +1,44 @@
+package main
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// Whether the orb announces its state in the terminal title, set by
+// --terminal-title
+var terminalTitles = true
+
+// title names what the orb is doing, so background tabs and screen readers
+// can follow along.
+func (m model) title() string {
+	switch {
+	case m.listening:
+		return "Orb — listening…"
+	case m.thinking:
+		return "Orb — pondering…"
+	case m.meditation != nil:
+		return "Orb — breathing"
+	case m.focus != nil:
+		return "Orb — focusing"
+	case m.paths != nil:
+		return "Orb — paths offered"
+	case m.showingAnswer && m.seal == "" && m.question != "":
+		return "Orb — the cosmos is silent"
+	case m.showingAnswer:
+		return "Orb — answer ready"
+	default:
+		return "Orb of Pondering"
+	}
+}
+
+// announceState follows an update with a title change (OSC 2) whenever the
+// orb's state changed.
+func announceState(next tea.Model, cmd tea.Cmd) (tea.Model, tea.Cmd) {
+	m, ok := next.(model)
+	if !ok || !terminalTitles {
+		return next, cmd
+	}
+	if t := m.title(); t != m.shownTitle {
+		m.shownTitle = t
+		return m, tea.Batch(cmd, tea.SetWindowTitle(t))
+	}
+	return m, cmd
+}
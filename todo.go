@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// File answers are added to as tasks with t, set by --todo-file. A .md
+// file gets Markdown checkboxes, as in an Obsidian inbox; anything else
+// gets todo.txt lines.
+var todoPath = ""
+
+// A message for when an answer has been added to the todo file
+type todoAddedMsg struct {
+	path string
+	err  error
+}
+
+// todoItem writes an answer as one task, with the question it answered
+// for context.
+func todoItem(path, question, answer string, now time.Time) string {
+	answer = strings.Join(strings.Fields(answer), " ")
+	question = strings.Join(strings.Fields(question), " ")
+	date := now.Format("2006-01-02")
+	if strings.EqualFold(filepath.Ext(path), ".md") {
+		return fmt.Sprintf("- [ ] %s (asked the orb: %s) ➕ %s\n", answer, question, date)
+	}
+	return fmt.Sprintf("%s %s (asked the orb: %s) +orb\n", date, answer, question)
+}
+
+// addTodoCmd appends an answer to the todo file.
+func addTodoCmd(path, question, answer string) tea.Cmd {
+	return func() tea.Msg {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return todoAddedMsg{path: path, err: fmt.Errorf("failed to open todo file: %w", err)}
+		}
+		defer f.Close()
+		if _, err := f.WriteString(todoItem(path, question, answer, time.Now())); err != nil {
+			return todoAddedMsg{path: path, err: fmt.Errorf("failed to write todo file: %w", err)}
+		}
+		return todoAddedMsg{path: path}
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/charmbracelet/wish/scp"
+)
+
+// transcriptDelivery lets clients download their own history with scp,
+// generated for their key when they ask:
+//
+//	scp -P 2222 ponder.guru:transcript.txt .
+//	scp -P 2222 ponder.guru:history.jsonl .
+//
+// Nothing can be copied to the server. Newer scp clients use SFTP, which
+// transcriptSFTP serves.
+func transcriptDelivery() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			if !scp.GetInfo(s.Command()).Ok {
+				next(s)
+				return
+			}
+			fsys, ok := sessionTranscripts(s)
+			if !ok {
+				return
+			}
+			handler := &ackCounter{CopyToClientHandler: scp.NewFSReadHandler(fsys)}
+			scp.Middleware(handler, nil)(next)(s)
+			// The client acknowledges everything it's sent, and loses the
+			// connection if we hang up before it has
+			awaitAcks(s, handler.acks)
+			s.Exit(0) // Already sent if the copy failed
+		}
+	}
+}
+
+// sessionTranscripts generates the transcripts of the session's key,
+// telling the client why when it can't.
+func sessionTranscripts(s ssh.Session) (fs.FS, bool) {
+	identity := keyFingerprint(s)
+	if identity == "" {
+		wish.Fatalln(s, "the orb only keeps transcripts for those who bring an ssh key")
+		return nil, false
+	}
+	layout := defaultTextLayout.apply(sessionEnv(s.Environ(), acceptedEnv))
+	fsys, err := transcriptFS(identity, layout, time.Now())
+	if err != nil {
+		sessionLogger(s).Error("generating transcripts", "err", err)
+		wish.Fatalln(s, "the orb's memory is clouded")
+		return nil, false
+	}
+	return fsys, true
+}
+
+// How long an scp client gets to acknowledge the end of a download
+const ackTimeout = 30 * time.Second
+
+// awaitAcks waits for n acknowledgements from an scp client, or until
+// ackTimeout for one that never sends them.
+func awaitAcks(s ssh.Session, n int) {
+	done := make(chan struct{})
+	go func() {
+		io.ReadFull(s, make([]byte, n))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(ackTimeout):
+		sessionLogger(s).Warn("scp client never acknowledged the download", "acks", n)
+	}
+}
+
+// ackCounter counts the acknowledgements an scp client owes for the
+// entries sent to it: one to start, and one per line and file.
+type ackCounter struct {
+	scp.CopyToClientHandler
+	acks int
+}
+
+func (a *ackCounter) NewDirEntry(s ssh.Session, path string) (*scp.DirEntry, error) {
+	e, err := a.CopyToClientHandler.NewDirEntry(s, path)
+	if err == nil {
+		a.owe(e.Mtime, e.Atime, 2) // D and E
+	}
+	return e, err
+}
+
+func (a *ackCounter) NewFileEntry(s ssh.Session, path string) (*scp.FileEntry, func() error, error) {
+	e, closer, err := a.CopyToClientHandler.NewFileEntry(s, path)
+	if err == nil {
+		a.owe(e.Mtime, e.Atime, 2) // C and the contents
+	}
+	return e, closer, err
+}
+
+func (a *ackCounter) owe(mtime, atime int64, n int) {
+	if a.acks == 0 {
+		a.acks = 1
+	}
+	if mtime > 0 && atime > 0 {
+		n++ // T
+	}
+	a.acks += n
+}
+
+// transcriptFS generates the files a client can download, with answers in
+// the transcript laid out as the client prefers.
+func transcriptFS(identity string, layout textLayout, now time.Time) (fs.FS, error) {
+	records, err := loadHistory(identity, math.MaxInt)
+	if err != nil {
+		return nil, err
+	}
+	// Oldest first, as they were asked
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+
+	var transcript, jsonl bytes.Buffer
+	fmt.Fprintf(&transcript, "Orb of Pondering transcript for %s\nwritten %s\n", displayName(identity), now.UTC().Format(time.RFC1123))
+	enc := json.NewEncoder(&jsonl)
+	for _, rec := range records {
+		answer := rec.Answer
+		if rec.Failed {
+			answer = "(the orb was silent)"
+		}
+		answer = layout.export(answer)
+		fmt.Fprintf(&transcript, "\n%s\n? %s\n%s\n", rec.Time.UTC().Format("2006-01-02 15:04 MST"), rec.Question, answer)
+		if rec.Seal != "" {
+			fmt.Fprintln(&transcript, strings.TrimSpace(rec.Seal+" "+rec.Signature))
+		}
+		if err := enc.Encode(rec); err != nil {
+			return nil, fmt.Errorf("failed to encode history: %w", err)
+		}
+	}
+	return memFS{modTime: now, files: map[string][]byte{
+		"transcript.txt": transcript.Bytes(),
+		"history.jsonl":  jsonl.Bytes(),
+	}}, nil
+}
+
+// A read-only directory of generated files
+type memFS struct {
+	files   map[string][]byte
+	modTime time.Time
+}
+
+func (m memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return &memDir{fs: m}, nil
+	}
+	data, ok := m.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &memFile{Reader: bytes.NewReader(data), info: m.info(name)}, nil
+}
+
+func (m memFS) info(name string) memInfo {
+	return memInfo{name: name, size: int64(len(m.files[name])), modTime: m.modTime}
+}
+
+type memInfo struct {
+	name    string
+	size    int64
+	dir     bool
+	modTime time.Time
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) ModTime() time.Time { return i.modTime }
+func (i memInfo) IsDir() bool        { return i.dir }
+func (i memInfo) Sys() any           { return nil }
+
+func (i memInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+type memFile struct {
+	*bytes.Reader
+	info memInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+type memDir struct {
+	fs   memFS
+	read bool
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) {
+	return memInfo{name: ".", dir: true, modTime: d.fs.modTime}, nil
+}
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: ".", Err: errors.New("is a directory")}
+}
+
+func (d *memDir) Close() error { return nil }
+
+// ReadDir lists the files in one go.
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d.read {
+		if n > 0 {
+			return nil, io.EOF
+		}
+		return nil, nil
+	}
+	d.read = true
+	var entries []fs.DirEntry
+	for name := range d.fs.files {
+		entries = append(entries, fs.FileInfoToDirEntry(d.fs.info(name)))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
@@ -0,0 +1,33 @@
+#!/bin/sh
+# Fetches the pinned xterm.js and addon-fit releases into web/xterm, where
+# the web terminal embeds them from at build time. Each tarball is checked
+# against the integrity hash the npm registry publishes for it.
+set -eu
+
+dir=$(cd "$(dirname "$0")" && pwd)/xterm
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+fetch() { # package version dest files...
+	pkg=$1 version=$2 dest=$3
+	shift 3
+	name=${pkg#*/}
+	url=https://registry.npmjs.org/$pkg/-/$name-$version.tgz
+	want=$(curl -fsSL "https://registry.npmjs.org/$pkg/$version" | grep -o '"integrity":"sha512-[^"]*"' | head -n 1 | cut -d'"' -f4)
+	curl -fsSL -o "$tmp/$name.tgz" "$url"
+	got=sha512-$(openssl dgst -sha512 -binary "$tmp/$name.tgz" | base64 | tr -d '\n')
+	if [ -z "$want" ] || [ "$got" != "$want" ]; then
+		echo "integrity mismatch for $pkg@$version: got $got, want $want" >&2
+		exit 1
+	fi
+	mkdir -p "$tmp/$name"
+	tar -xzf "$tmp/$name.tgz" -C "$tmp/$name"
+	for file in "$@"; do
+		mkdir -p "$dir/$dest/$(dirname "$file")"
+		cp "$tmp/$name/package/$file" "$dir/$dest/$file"
+	done
+	echo "fetched $pkg@$version"
+}
+
+fetch @xterm/xterm 5.5.0 xterm lib/xterm.js css/xterm.css LICENSE
+fetch @xterm/addon-fit 0.10.0 addon-fit lib/addon-fit.js LICENSE
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/ssh"
+	"github.com/muesli/termenv"
+)
+
+// Where the browser loads xterm.js from. Set by --web-assets to a local
+// copy of the @xterm/xterm and @xterm/addon-fit packages; otherwise the
+// copy web/fetch-xterm.sh put in web/xterm before the build is served.
+var webAssetsDir = ""
+
+//go:embed all:web/xterm
+var embeddedXterm embed.FS
+
+// xtermAssets returns the xterm.js packages the browser is served, or an
+// error when there are none to serve.
+func xtermAssets() (fs.FS, error) {
+	assets, err := fs.Sub(embeddedXterm, "web/xterm")
+	if webAssetsDir != "" {
+		assets = os.DirFS(webAssetsDir)
+	}
+	if err == nil {
+		_, err = fs.Stat(assets, "xterm/lib/xterm.js")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no xterm.js to serve (run web/fetch-xterm.sh before building, or pass --web-assets): %w", err)
+	}
+	return assets, nil
+}
+
+// serveWebTerminal hosts the orb in the browser for people without an ssh
+// client, running the same model as ssh sessions over a WebSocket. Every
+// script the page loads comes from the orb itself.
+func serveWebTerminal(addr string) {
+	assets, err := xtermAssets()
+	if err != nil {
+		slog.Error("web terminal not started", "err", err)
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, webTerminalPage)
+	})
+	mux.Handle("/xterm/", http.StripPrefix("/xterm/", http.FileServerFS(assets)))
+	mux.HandleFunc("/tty", handleWebTerminal)
+
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		slog.Error("web terminal stopped", "err", err)
+	}
+}
+
+// A message from the browser terminal: keys typed or a new window size
+type webTerminalMsg struct {
+	Type string `json:"type"` // "input" or "resize"
+	Data string `json:"data"`
+	Cols int    `json:"cols"`
+	Rows int    `json:"rows"`
+}
+
+// webTerminalOutput sends program output to the browser as binary frames,
+// since a write may end partway through a UTF-8 sequence.
+type webTerminalOutput struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (o *webTerminalOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := writeWebSocketFrame(o.conn, 0x2, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// handleWebTerminal upgrades a browser's request to a websocket and runs an
+// orb session over it, as an ssh session would run, until either side
+// hangs up.
+func handleWebTerminal(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin websocket requests are not allowed", http.StatusForbidden)
+		return
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	logger := slog.With("remote", host, "web", true)
+	// The same limits as ssh sessions, so browsers can't get round them
+	if wait := connectionWait(host); wait > 0 {
+		logger.Warn("too many connections")
+		http.Error(w, tryAgainIn(wait), http.StatusTooManyRequests)
+		return
+	}
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer conn.Close()
+	info := &sessionInfo{
+		id:      "web-" + rand.Text(),
+		remote:  r.RemoteAddr,
+		client:  host,
+		web:     true,
+		started: time.Now(),
+		close:   conn.Close,
+	}
+	release, ok := trackSession(info, logger)
+	if !ok {
+		writeWebSocketFrame(conn, 0x2, []byte("\r\n"+crowded+"\r\n"))
+		writeWebSocketFrame(conn, 0x8, nil)
+		return
+	}
+	defer release()
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	out := &webTerminalOutput{conn: conn}
+	renderer := lipgloss.NewRenderer(out, termenv.WithProfile(termenv.TrueColor))
+	renderer.SetHasDarkBackground(true) // Don't query a browser that can't answer
+
+	usage.session()
+	m := initialModel()
+	cols, _ := strconv.Atoi(r.URL.Query().Get("cols"))
+	rows, _ := strconv.Atoi(r.URL.Query().Get("rows"))
+	m.width, m.height = clampSize(cols, rows)
+	m.useRenderer(renderer)
+	m.user, m.client, m.addr = "web:"+host, host, host
+	m.logger = logger
+	m.session, m.sessionID = ctx, info.id
+	m.showBanner()
+
+	input, feed := io.Pipe()
+	p := tea.NewProgram(m,
+		tea.WithInput(input),
+		tea.WithOutput(out),
+		tea.WithAltScreen(),
+		tea.WithContext(ctx),
+		tea.WithoutSignalHandler(),
+		tea.WithEnvironment([]string{"TERM=xterm-256color"}),
+	)
+
+	resizes := make(chan ssh.Window)
+	go forwardResizes(ctx, p, resizes)
+	go func() {
+		defer cancel()
+		defer feed.Close()
+		for {
+			_, payload, err := readWebSocketMessage(conn)
+			if err != nil {
+				return
+			}
+			var msg webTerminalMsg
+			if err := json.Unmarshal(payload, &msg); err != nil {
+				continue
+			}
+			switch msg.Type {
+			case "input":
+				if _, err := io.WriteString(feed, msg.Data); err != nil {
+					return
+				}
+			case "resize":
+				select {
+				case resizes <- ssh.Window{Width: msg.Cols, Height: msg.Rows}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	if _, err := p.Run(); err != nil && ctx.Err() == nil {
+		m.logger.Error("running web terminal session", "err", err)
+	}
+	out.mu.Lock()
+	writeWebSocketFrame(conn, 0x8, nil)
+	out.mu.Unlock()
+}
+
+// The browser terminal: xterm.js filling the window, wired to /tty
+const webTerminalPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Orb of Pondering</title>
+<link rel="stylesheet" href="/xterm/xterm/css/xterm.css">
+<style>
+  html, body { margin: 0; height: 100%; background: #000; }
+  #orb { height: 100%; }
+</style>
+</head>
+<body>
+<div id="orb"></div>
+<script src="/xterm/xterm/lib/xterm.js"></script>
+<script src="/xterm/addon-fit/lib/addon-fit.js"></script>
+<script>
+const term = new Terminal({ cursorBlink: true, theme: { background: "#000000" } });
+const fit = new FitAddon.FitAddon();
+term.loadAddon(fit);
+term.open(document.getElementById("orb"));
+fit.fit();
+
+const scheme = location.protocol === "https:" ? "wss://" : "ws://";
+const ws = new WebSocket(scheme + location.host + "/tty?cols=" + term.cols + "&rows=" + term.rows);
+ws.binaryType = "arraybuffer";
+ws.onopen = () => {
+  ws.send(JSON.stringify({ type: "resize", cols: term.cols, rows: term.rows }));
+  term.focus();
+};
+ws.onmessage = (e) => term.write(new Uint8Array(e.data));
+ws.onclose = () => term.write("\r\n\x1b[2mThe orb has gone quiet. Reload to ask again.\x1b[0m\r\n");
+
+term.onData((data) => ws.readyState === WebSocket.OPEN && ws.send(JSON.stringify({ type: "input", data })));
+term.onResize(({ cols, rows }) => ws.readyState === WebSocket.OPEN && ws.send(JSON.stringify({ type: "resize", cols, rows })));
+window.addEventListener("resize", () => fit.fit());
+</script>
+</body>
+</html>
+`